@@ -2,6 +2,8 @@ package aes
 
 import (
 	"errors"
+
+	"github.com/anhk/crypto/internal/alias"
 )
 
 const (
@@ -28,15 +30,34 @@ type AES struct {
 	 */
 	roundKey [240]byte
 
+	/**
+	 * 查表实现使用的轮密钥，enc 为 roundKey 的大端序字，dec 为等价逆密码的轮密钥
+	 */
+	enc [60]uint32
+	dec [60]uint32
+
+	/**
+	 * AES-NI 解密 (AESDEC) 使用的轮密钥，即 dec 的字节形式
+	 */
+	decRoundKey [240]byte
+
+	/**
+	 * 是否使用常数时间实现，见 WithConstantTime
+	 */
+	constantTime bool
+
 	/**
 	 * 密钥，AES-128: 16 bytes, AES-256: 32 bytes
 	 */
 	//	Key []byte
 }
 
-//NewCipher -
-func NewCipher(key []byte) (*AES, error) {
+//NewCipher - 默认使用查表实现，可通过 WithConstantTime 选择常数时间实现
+func NewCipher(key []byte, opts ...Option) (*AES, error) {
 	aes := &AES{}
+	for _, opt := range opts {
+		opt(aes)
+	}
 	switch l := len(key); l {
 	case 16:
 		aes.nbk, aes.nr = 4, 10
@@ -48,6 +69,7 @@ func NewCipher(key []byte) (*AES, error) {
 		return nil, errors.New("Invalid size of key")
 	}
 	aes.keyExpansion(key)
+	aes.expandTableKeys()
 	return aes, nil
 }
 
@@ -71,7 +93,8 @@ func (aes *AES) keyExpansion(key []byte) {
 	 * - AES-256: i= 8 ~ 59, 共1+14个blocks
 	 */
 	for i := aes.nbk; i < (nb * (aes.nr + 1)); i++ {
-		tempBytes := make([]byte, 4)
+		var temp [4]byte
+		tempBytes := temp[:]
 		copy(tempBytes, aes.roundKey[(i-1)*4:i*4])
 		if i%aes.nbk == 0 {
 			// RotWord, [a0,a1,a2,a3] left circular shift -> [a1, a2, a3, a0]
@@ -102,6 +125,10 @@ func (aes *AES) AddRoundKey(roundKey, block []byte) {
 
 //SubBytes - TODO: BIJECTION.
 func (aes *AES) SubBytes(block []byte) {
+	if aes.constantTime {
+		subBytesCT(block)
+		return
+	}
 	for i := range block {
 		block[i] = SBox[block[i]]
 	}
@@ -109,6 +136,10 @@ func (aes *AES) SubBytes(block []byte) {
 
 //UnSubBytes - TODO: BIJECTION.
 func (aes *AES) UnSubBytes(block []byte) {
+	if aes.constantTime {
+		invSubBytesCT(block)
+		return
+	}
 	for i := range block {
 		block[i] = InvSBox[block[i]]
 	}
@@ -116,22 +147,24 @@ func (aes *AES) UnSubBytes(block []byte) {
 
 //ShiftRows -
 func (aes *AES) ShiftRows(block []byte) {
-	copy(block, []byte{
+	tmp := [BlockSize]byte{
 		block[0], block[5], block[10], block[15],
 		block[4], block[9], block[14], block[3],
 		block[8], block[13], block[2], block[7],
 		block[12], block[1], block[6], block[11],
-	})
+	}
+	copy(block, tmp[:])
 }
 
 //UnShiftRows -
 func (aes *AES) UnShiftRows(block []byte) {
-	copy(block, []byte{
+	tmp := [BlockSize]byte{
 		block[0], block[13], block[10], block[7],
 		block[4], block[1], block[14], block[11],
 		block[8], block[5], block[2], block[15],
 		block[12], block[9], block[6], block[3],
-	})
+	}
+	copy(block, tmp[:])
 }
 
 /**
@@ -141,79 +174,104 @@ func (aes *AES) UnShiftRows(block []byte) {
  *    c2      | 1 1 2 3 |    |b2|
  *    c3      | 3 1 1 2 |    |b3|
  */
-func (aes *AES) mixColumn(a, b []byte) []byte {
-	out := make([]byte, 4)
-
-	out[0] = gmult(a[0], b[0]) ^ gmult(a[3], b[1]) ^ gmult(a[2], b[2]) ^ gmult(a[1], b[3])
-	out[1] = gmult(a[1], b[0]) ^ gmult(a[0], b[1]) ^ gmult(a[3], b[2]) ^ gmult(a[2], b[3])
-	out[2] = gmult(a[2], b[0]) ^ gmult(a[1], b[1]) ^ gmult(a[0], b[2]) ^ gmult(a[3], b[3])
-	out[3] = gmult(a[3], b[0]) ^ gmult(a[2], b[1]) ^ gmult(a[1], b[2]) ^ gmult(a[0], b[3])
-	return out
+func (aes *AES) mixColumn(b []byte) {
+	b0, b1, b2, b3 := b[0], b[1], b[2], b[3]
+	t := b0 ^ b1 ^ b2 ^ b3
+	b[0] = b0 ^ t ^ xtime(b0^b1)
+	b[1] = b1 ^ t ^ xtime(b1^b2)
+	b[2] = b2 ^ t ^ xtime(b2^b3)
+	b[3] = b3 ^ t ^ xtime(b3^b0)
 }
 
 //MixColumns -
 func (aes *AES) MixColumns(block []byte) {
-	a := []byte{0x02, 0x01, 0x01, 0x03}
 	for i := 0; i < len(block); i += 4 {
-		tmp := aes.mixColumn(a, block[i:i+4])
-		copy(block[i:i+4], tmp)
+		aes.mixColumn(block[i : i+4])
 	}
 }
 
 //UnMixColumns -
 func (aes *AES) UnMixColumns(block []byte) {
-	a := []byte{0x0e, 0x09, 0x0d, 0x0b}
+	/**
+	 * | e b d 9 |   | 2 3 1 1 |   | 5 0 4 0 |
+	 * | 9 e b d | = | 1 2 3 1 | * | 0 5 0 4 |
+	 * | d 9 e b |   | 1 1 2 3 |   | 4 0 5 0 |
+	 * | b d 9 e |   | 3 1 1 2 |   | 0 4 0 5 |
+	 */
 	for i := 0; i < len(block); i += 4 {
-		tmp := aes.mixColumn(a, block[i:i+4])
-		copy(block[i:i+4], tmp)
+		b := block[i : i+4]
+		u := xtime(xtime(b[0] ^ b[2]))
+		v := xtime(xtime(b[1] ^ b[3]))
+		b[0] ^= u
+		b[1] ^= v
+		b[2] ^= u
+		b[3] ^= v
+		aes.mixColumn(b)
 	}
 }
 
+/**
+ * 乘以 x，无分支
+ */
+func xtime(a byte) byte {
+	return a<<1 ^ (0x1b & -(a >> 7))
+}
+
+/**
+ * GF(2^8) 乘法，无分支
+ */
 func gmult(a, b byte) byte {
 	p := byte(0)
 	for i := 0; i < 8; i++ {
-		if (b & 1) != 0 {
-			p ^= a
-		}
-		hbs := a & 0x80
-
-		a <<= 1
-		if hbs != 0 {
-			a ^= 0x1b
-		}
+		p ^= a & -(b & 1)
+		a = xtime(a)
 		b >>= 1
 	}
 	return p
 }
 
-//Encrypt -
+/**
+ * 与 crypto/cipher.Block 的约定一致: 输入输出不足一个分组，
+ * 或两者错位重叠时 panic，dst 与 src 完全相同(原地加解密)是允许的
+ */
+func checkBlock(dst, src []byte) {
+	if len(src) < BlockSize {
+		panic("aes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("aes: output not full block")
+	}
+	if alias.InexactOverlap(dst[:BlockSize], src[:BlockSize]) {
+		panic("aes: invalid buffer overlap")
+	}
+}
+
+//Encrypt - 支持 AES-NI 时使用汇编实现，否则使用查表实现，每个分组不分配内存
 func (aes *AES) Encrypt(dst, src []byte) {
-	copy(dst, src)
-	aes.AddRoundKey(aes.roundKey[:16], dst)
-	for i := 1; i < aes.nr; i++ {
-		aes.SubBytes(dst)
-		aes.ShiftRows(dst)
-		aes.MixColumns(dst)
-		aes.AddRoundKey(aes.roundKey[i*16:(i+1)*16], dst)
+	checkBlock(dst, src)
+	if useAsm {
+		encryptBlockAsm(aes.nr, &aes.roundKey[0], &dst[0], &src[0])
+		return
+	}
+	if aes.constantTime {
+		aes.encryptSteps(dst, src)
+		return
 	}
-	aes.SubBytes(dst)
-	aes.ShiftRows(dst)
-	aes.AddRoundKey(aes.roundKey[aes.nr*16:], dst)
+	encryptBlock(aes.enc[:], aes.nr, dst, src)
 }
 
 //Decrypt -
 func (aes *AES) Decrypt(dst, src []byte) {
-	copy(dst, src)
-	aes.AddRoundKey(aes.roundKey[aes.nr*16:], dst)
-	aes.UnShiftRows(dst)
-	aes.UnSubBytes(dst)
-	for i := aes.nr - 1; i >= 1; i-- {
-		aes.AddRoundKey(aes.roundKey[i*16:(i+1)*16], dst)
-		aes.UnMixColumns(dst)
-		aes.UnShiftRows(dst)
-		aes.UnSubBytes(dst)
-	}
-	aes.AddRoundKey(aes.roundKey[:16], dst)
+	checkBlock(dst, src)
+	if useAsm {
+		decryptBlockAsm(aes.nr, &aes.decRoundKey[0], &dst[0], &src[0])
+		return
+	}
+	if aes.constantTime {
+		aes.decryptSteps(dst, src)
+		return
+	}
+	decryptBlock(aes.dec[:], aes.nr, dst, src)
 }
 
 func (aes *AES) BlockSize() int {
@@ -0,0 +1,19 @@
+package aes
+
+import (
+	"github.com/anhk/crypto/internal/cpuid"
+)
+
+/**
+ * 支持 AES-NI 时使用汇编实现，AES-NI 本身与数据无关，也是常数时间的
+ * useAsm 可在测试中关闭，以便同时测试纯 Go 实现
+ */
+var supportsAsm = cpuid.HasAES
+
+var useAsm = supportsAsm
+
+//go:noescape
+func encryptBlockAsm(nr int, xk *byte, dst, src *byte)
+
+//go:noescape
+func decryptBlockAsm(nr int, xk *byte, dst, src *byte)
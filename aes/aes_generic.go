@@ -0,0 +1,16 @@
+//go:build !amd64
+// +build !amd64
+
+package aes
+
+const supportsAsm = false
+
+var useAsm = false
+
+func encryptBlockAsm(nr int, xk *byte, dst, src *byte) {
+	panic("aes: assembly not supported")
+}
+
+func decryptBlockAsm(nr int, xk *byte, dst, src *byte) {
+	panic("aes: assembly not supported")
+}
@@ -2,7 +2,13 @@ package aes
 
 import (
 	"bytes"
+	stdaes "crypto/aes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"testing"
+
+	"github.com/anhk/crypto/keywrap"
 )
 
 var key = []byte{
@@ -44,6 +50,20 @@ var roundKey = []byte{
 	0x11, 0x73, 0xFD, 0xDD, 0xC2, 0xD1, 0x6D, 0x9E, 0x66, 0xEB, 0x35, 0xA7, 0x15, 0xD3, 0x45, 0xE2,
 }
 
+/**
+ * 依次使用纯 Go 实现和汇编实现 (CPU 支持时) 运行测试
+ */
+func forEachImplementation(t *testing.T, f func(t *testing.T)) {
+	defer func(saved bool) { useAsm = saved }(useAsm)
+
+	useAsm = false
+	t.Run("generic", f)
+	if supportsAsm {
+		useAsm = true
+		t.Run("asm", f)
+	}
+}
+
 func TestAES_AddRoundKey(t *testing.T) {
 	aes, _ := NewCipher(key[:])
 	if !bytes.Equal(aes.roundKey[:], roundKey) {
@@ -56,8 +76,9 @@ func TestAES_AddRoundKey(t *testing.T) {
 */
 func TestAES_Encrypt(t *testing.T) {
 	aes, _ := NewCipher(key[:])
-	aes.Encrypt(data, data)
-	if !bytes.Equal(data, encData) {
+	buf := append([]byte{}, data...)
+	aes.Encrypt(buf, buf)
+	if !bytes.Equal(buf, encData) {
 		t.Fatal("invalid roundkey")
 	}
 }
@@ -67,8 +88,303 @@ func TestAES_Encrypt(t *testing.T) {
 */
 func TestAES_Decrypt(t *testing.T) {
 	aes, _ := NewCipher(key[:])
-	aes.Decrypt(data, data)
-	if !bytes.Equal(data, decData) {
+	buf := append([]byte{}, data...)
+	aes.Decrypt(buf, buf)
+	if !bytes.Equal(buf, decData) {
 		t.Fatal("invalid roundkey")
 	}
 }
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * RFC 3610 Packet Vector #1
+ */
+func TestNewCCM(t *testing.T) {
+	key := mustHex("C0C1C2C3C4C5C6C7C8C9CACBCCCDCECF")
+	nonce := mustHex("00000003020100A0A1A2A3A4A5")
+	aad := mustHex("0001020304050607")
+	plain := mustHex("08090A0B0C0D0E0F101112131415161718191A1B1C1D1E")
+	expected := mustHex("588C979A61C663D2F066D0C2C0F989806D5F6B61DAC38417E8D12CFDF926E0")
+
+	aead, err := NewCCM(key, len(nonce), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := aead.Seal(nil, nonce, plain, aad)
+	if !bytes.Equal(ct, expected) {
+		t.Fatal("invalid seal")
+	}
+
+	pt, err := aead.Open(nil, nonce, ct, aad)
+	if err != nil || !bytes.Equal(pt, plain) {
+		t.Fatal("invalid open")
+	}
+}
+
+/**
+ * RFC 3394 4.6 与 RFC 5649 第6节
+ */
+func TestWrapKey(t *testing.T) { forEachImplementation(t, testWrapKey) }
+
+func testWrapKey(t *testing.T) {
+	kek := mustHex("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	dataKey := mustHex("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	aes, _ := NewCipher(kek)
+	wrapped, err := aes.WrapKey(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(wrapped) != "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21" {
+		t.Fatalf("invalid wrap %x", wrapped)
+	}
+	if out, err := aes.UnwrapKey(wrapped); err != nil || !bytes.Equal(out, dataKey) {
+		t.Fatal("invalid unwrap")
+	}
+
+	wrapped[0] ^= 1
+	var integrityErr keywrap.IntegrityError
+	if _, err := aes.UnwrapKey(wrapped); !errors.As(err, &integrityErr) {
+		t.Fatal("expected IntegrityError, got", err)
+	}
+
+	kek = mustHex("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	aes, _ = NewCipher(kek)
+	wrapped, _ = aes.WrapKeyWithPadding([]byte("ForPasi"))
+	if hex.EncodeToString(wrapped) != "afbeb0f07dfbf5419200f2ccb50bb24f" {
+		t.Fatalf("invalid padded wrap %x", wrapped)
+	}
+	if out, err := aes.UnwrapKeyWithPadding(wrapped); err != nil || string(out) != "ForPasi" {
+		t.Fatal("invalid padded unwrap")
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, keySize int) {
+	aes, _ := NewCipher(key[:keySize])
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aes.Encrypt(buf, buf)
+	}
+}
+
+func BenchmarkEncrypt128(b *testing.B) { benchmarkEncrypt(b, 16) }
+func BenchmarkEncrypt256(b *testing.B) { benchmarkEncrypt(b, 32) }
+
+func BenchmarkEncryptConstantTime(b *testing.B) {
+	aes, _ := NewCipher(key[:16], WithConstantTime())
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aes.Encrypt(buf, buf)
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	aes, _ := NewCipher(key[:16])
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aes.Decrypt(buf, buf)
+	}
+}
+
+func BenchmarkNewCipher(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewCipher(key)
+	}
+}
+
+/**
+ * FIPS 197 附录C
+ */
+func TestFIPS197(t *testing.T) { forEachImplementation(t, testFIPS197) }
+
+func testFIPS197(t *testing.T) {
+	plain := mustHex("00112233445566778899aabbccddeeff")
+	tests := []struct {
+		key, cipher string
+	}{
+		{"000102030405060708090a0b0c0d0e0f", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+		{"000102030405060708090a0b0c0d0e0f1011121314151617", "dda97ca4864cdfe06eaf70a0ec0d7191"},
+		{"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "8ea2b7ca516745bfeafc49904b496089"},
+	}
+	for _, test := range tests {
+		k := mustHex(test.key)
+		aes, err := NewCipher(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, BlockSize)
+		aes.Encrypt(buf, plain)
+		if hex.EncodeToString(buf) != test.cipher {
+			t.Fatal("invalid encrypt with", len(k), "byte key")
+		}
+		aes.Decrypt(buf, buf)
+		if !bytes.Equal(buf, plain) {
+			t.Fatal("invalid decrypt with", len(k), "byte key")
+		}
+	}
+}
+
+/**
+ * 查表实现与逐步骤实现、标准库比较
+ */
+func TestTableImplementation(t *testing.T) { forEachImplementation(t, testTableImplementation) }
+
+func testTableImplementation(t *testing.T) {
+	for _, keySize := range []int{16, 24, 32} {
+		k := make([]byte, keySize)
+		src := make([]byte, BlockSize)
+		for i := 0; i < 100; i++ {
+			rand.Read(k)
+			rand.Read(src)
+			aes, _ := NewCipher(k)
+			std, _ := stdaes.NewCipher(k)
+
+			var got, want, ref [BlockSize]byte
+			aes.Encrypt(got[:], src)
+			std.Encrypt(want[:], src)
+			aes.encryptSteps(ref[:], src)
+			if got != want || got != ref {
+				t.Fatalf("encrypt mismatch with key %x", k)
+			}
+
+			aes.Decrypt(got[:], src)
+			std.Decrypt(want[:], src)
+			aes.decryptSteps(ref[:], src)
+			if got != want || got != ref {
+				t.Fatalf("decrypt mismatch with key %x", k)
+			}
+		}
+	}
+}
+
+func TestConstantTimeSBox(t *testing.T) {
+	var in, out [256]byte
+	for i := range in {
+		in[i] = byte(i)
+	}
+
+	copy(out[:], in[:])
+	subBytesCT(out[:])
+	for i := range out {
+		if out[i] != SBox[i] {
+			t.Fatalf("S(%02x) = %02x, want %02x", i, out[i], SBox[i])
+		}
+	}
+
+	copy(out[:], in[:])
+	invSubBytesCT(out[:])
+	for i := range out {
+		if out[i] != InvSBox[i] {
+			t.Fatalf("S^-1(%02x) = %02x, want %02x", i, out[i], InvSBox[i])
+		}
+	}
+}
+
+/**
+ * 常数时间实现与查表实现比较
+ */
+func TestConstantTime(t *testing.T) { forEachImplementation(t, testConstantTime) }
+
+func testConstantTime(t *testing.T) {
+	for _, keySize := range []int{16, 24, 32} {
+		k := make([]byte, keySize)
+		src := make([]byte, BlockSize)
+		for i := 0; i < 50; i++ {
+			rand.Read(k)
+			rand.Read(src)
+			table, _ := NewCipher(k)
+			ct, _ := NewCipher(k, WithConstantTime())
+			if ct.roundKey != table.roundKey {
+				t.Fatalf("key expansion mismatch with key %x", k)
+			}
+
+			/**
+			 * 解密轮密钥由无分支的 InvMixColumns 计算，应与查表结果一致
+			 */
+			if ct.dec != table.dec || ct.decRoundKey != table.decRoundKey {
+				t.Fatalf("decryption key schedule mismatch with key %x", k)
+			}
+
+			var got, want [BlockSize]byte
+			ct.Encrypt(got[:], src)
+			table.Encrypt(want[:], src)
+			if got != want {
+				t.Fatalf("encrypt mismatch with key %x", k)
+			}
+
+			ct.Decrypt(got[:], src)
+			table.Decrypt(want[:], src)
+			if got != want {
+				t.Fatalf("decrypt mismatch with key %x", k)
+			}
+		}
+	}
+
+	aes, _ := NewCipher(key, WithConstantTime())
+	buf := append([]byte{}, data...)
+	aes.Encrypt(buf, buf)
+	if !bytes.Equal(buf, encData) {
+		t.Fatal("invalid encrypt")
+	}
+}
+
+func TestEncryptAllocs(t *testing.T) { forEachImplementation(t, testEncryptAllocs) }
+
+func testEncryptAllocs(t *testing.T) {
+	aes, _ := NewCipher(key)
+	buf := make([]byte, BlockSize)
+	n := testing.AllocsPerRun(100, func() {
+		aes.Encrypt(buf, buf)
+		aes.Decrypt(buf, buf)
+	})
+	if n != 0 {
+		t.Fatal("unexpected allocations per block:", n)
+	}
+}
+
+/**
+ * 与 crypto/cipher.Block 一致: 不足一个分组或错位重叠时 panic
+ */
+func TestInvalidBuffers(t *testing.T) { forEachImplementation(t, testInvalidBuffers) }
+
+func testInvalidBuffers(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithConstantTime()}} {
+		aes, _ := NewCipher(key, opts...)
+		buf := make([]byte, 2*BlockSize)
+		for _, crypt := range []func(dst, src []byte){aes.Encrypt, aes.Decrypt} {
+			mustPanic(t, "short input", func() { crypt(buf, buf[:BlockSize-1]) })
+			mustPanic(t, "short output", func() { crypt(buf[:BlockSize-1], buf[BlockSize:]) })
+			mustPanic(t, "overlap", func() { crypt(buf[1:], buf) })
+			mustPanic(t, "overlap", func() { crypt(buf, buf[BlockSize-1:]) })
+
+			crypt(buf, buf)
+			crypt(buf[:BlockSize], buf[BlockSize:])
+		}
+	}
+}
+
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal(name, "did not panic")
+		}
+	}()
+	f()
+}
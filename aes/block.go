@@ -0,0 +1,116 @@
+package aes
+
+import (
+	"encoding/binary"
+)
+
+/**
+ * T 表 (查表实现)
+ * 将 SubBytes、ShiftRows、MixColumns 合并为每列4次查表和异或，每个字为一列，大端序
+ * - te0[x] = (2·S[x], S[x], S[x], 3·S[x])
+ * - td0[x] = (e·S⁻¹[x], 9·S⁻¹[x], d·S⁻¹[x], b·S⁻¹[x])
+ * te1 ~ te3、td1 ~ td3 分别为 te0、td0 循环右移 8、16、24 比特
+ */
+var te0, te1, te2, te3 [256]uint32
+var td0, td1, td2, td3 [256]uint32
+
+func init() {
+	for i := 0; i < 256; i++ {
+		s := SBox[i]
+		w := uint32(gmult(s, 2))<<24 | uint32(s)<<16 | uint32(s)<<8 | uint32(gmult(s, 3))
+		te0[i], te1[i], te2[i], te3[i] = w, w>>8|w<<24, w>>16|w<<16, w>>24|w<<8
+
+		s = InvSBox[i]
+		w = uint32(gmult(s, 0x0e))<<24 | uint32(gmult(s, 0x09))<<16 | uint32(gmult(s, 0x0d))<<8 | uint32(gmult(s, 0x0b))
+		td0[i], td1[i], td2[i], td3[i] = w, w>>8|w<<24, w>>16|w<<16, w>>24|w<<8
+	}
+}
+
+/**
+ * 由字节形式的轮密钥生成查表使用的轮密钥
+ * 解密使用等价逆密码 (FIPS 197 5.3.5): 轮密钥逆序，且除首尾两轮外需要经过 InvMixColumns
+ * 常数时间实现不以轮密钥为下标查表，InvMixColumns 使用无分支的 xtime 计算
+ */
+func (aes *AES) expandTableKeys() {
+	n := nb * (aes.nr + 1)
+	for i := 0; i < n; i++ {
+		aes.enc[i] = binary.BigEndian.Uint32(aes.roundKey[i*4:])
+	}
+	for i := 0; i < n; i += 4 {
+		ei := n - i - 4
+		inner := i > 0 && i+4 < n
+
+		var rk [BlockSize]byte
+		copy(rk[:], aes.roundKey[ei*4:(ei+4)*4])
+		if inner && aes.constantTime {
+			aes.UnMixColumns(rk[:])
+		}
+		for j := 0; j < 4; j++ {
+			x := binary.BigEndian.Uint32(rk[j*4:])
+			if inner && !aes.constantTime {
+				x = td0[SBox[x>>24]] ^ td1[SBox[x>>16&0xff]] ^ td2[SBox[x>>8&0xff]] ^ td3[SBox[x&0xff]]
+			}
+			aes.dec[i+j] = x
+			binary.BigEndian.PutUint32(aes.decRoundKey[(i+j)*4:], x)
+		}
+	}
+}
+
+func encryptBlock(xk []uint32, nr int, dst, src []byte) {
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ xk[3]
+
+	k := 4
+	var t0, t1, t2, t3 uint32
+	for r := 1; r < nr; r++ {
+		t0 = xk[k+0] ^ te0[s0>>24] ^ te1[s1>>16&0xff] ^ te2[s2>>8&0xff] ^ te3[s3&0xff]
+		t1 = xk[k+1] ^ te0[s1>>24] ^ te1[s2>>16&0xff] ^ te2[s3>>8&0xff] ^ te3[s0&0xff]
+		t2 = xk[k+2] ^ te0[s2>>24] ^ te1[s3>>16&0xff] ^ te2[s0>>8&0xff] ^ te3[s1&0xff]
+		t3 = xk[k+3] ^ te0[s3>>24] ^ te1[s0>>16&0xff] ^ te2[s1>>8&0xff] ^ te3[s2&0xff]
+		k += 4
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	/**
+	 * 最后一轮没有 MixColumns
+	 */
+	s0 = uint32(SBox[t0>>24])<<24 | uint32(SBox[t1>>16&0xff])<<16 | uint32(SBox[t2>>8&0xff])<<8 | uint32(SBox[t3&0xff])
+	s1 = uint32(SBox[t1>>24])<<24 | uint32(SBox[t2>>16&0xff])<<16 | uint32(SBox[t3>>8&0xff])<<8 | uint32(SBox[t0&0xff])
+	s2 = uint32(SBox[t2>>24])<<24 | uint32(SBox[t3>>16&0xff])<<16 | uint32(SBox[t0>>8&0xff])<<8 | uint32(SBox[t1&0xff])
+	s3 = uint32(SBox[t3>>24])<<24 | uint32(SBox[t0>>16&0xff])<<16 | uint32(SBox[t1>>8&0xff])<<8 | uint32(SBox[t2&0xff])
+
+	binary.BigEndian.PutUint32(dst[0:4], s0^xk[k+0])
+	binary.BigEndian.PutUint32(dst[4:8], s1^xk[k+1])
+	binary.BigEndian.PutUint32(dst[8:12], s2^xk[k+2])
+	binary.BigEndian.PutUint32(dst[12:16], s3^xk[k+3])
+}
+
+func decryptBlock(xk []uint32, nr int, dst, src []byte) {
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ xk[3]
+
+	k := 4
+	var t0, t1, t2, t3 uint32
+	for r := 1; r < nr; r++ {
+		t0 = xk[k+0] ^ td0[s0>>24] ^ td1[s3>>16&0xff] ^ td2[s2>>8&0xff] ^ td3[s1&0xff]
+		t1 = xk[k+1] ^ td0[s1>>24] ^ td1[s0>>16&0xff] ^ td2[s3>>8&0xff] ^ td3[s2&0xff]
+		t2 = xk[k+2] ^ td0[s2>>24] ^ td1[s1>>16&0xff] ^ td2[s0>>8&0xff] ^ td3[s3&0xff]
+		t3 = xk[k+3] ^ td0[s3>>24] ^ td1[s2>>16&0xff] ^ td2[s1>>8&0xff] ^ td3[s0&0xff]
+		k += 4
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	s0 = uint32(InvSBox[t0>>24])<<24 | uint32(InvSBox[t3>>16&0xff])<<16 | uint32(InvSBox[t2>>8&0xff])<<8 | uint32(InvSBox[t1&0xff])
+	s1 = uint32(InvSBox[t1>>24])<<24 | uint32(InvSBox[t0>>16&0xff])<<16 | uint32(InvSBox[t3>>8&0xff])<<8 | uint32(InvSBox[t2&0xff])
+	s2 = uint32(InvSBox[t2>>24])<<24 | uint32(InvSBox[t1>>16&0xff])<<16 | uint32(InvSBox[t0>>8&0xff])<<8 | uint32(InvSBox[t3&0xff])
+	s3 = uint32(InvSBox[t3>>24])<<24 | uint32(InvSBox[t2>>16&0xff])<<16 | uint32(InvSBox[t1>>8&0xff])<<8 | uint32(InvSBox[t0&0xff])
+
+	binary.BigEndian.PutUint32(dst[0:4], s0^xk[k+0])
+	binary.BigEndian.PutUint32(dst[4:8], s1^xk[k+1])
+	binary.BigEndian.PutUint32(dst[8:12], s2^xk[k+2])
+	binary.BigEndian.PutUint32(dst[12:16], s3^xk[k+3])
+}
@@ -0,0 +1,16 @@
+package aes
+
+import (
+	"crypto/cipher"
+
+	"github.com/anhk/crypto/ccm"
+)
+
+//NewCCM - 创建 AES-CCM，nonceSize 为 7 ~ 13 字节，tagSize 为 4 ~ 16 之间的偶数
+func NewCCM(key []byte, nonceSize, tagSize int) (cipher.AEAD, error) {
+	block, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return ccm.NewCCM(block, nonceSize, tagSize)
+}
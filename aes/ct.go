@@ -0,0 +1,100 @@
+package aes
+
+import (
+	"github.com/anhk/crypto/internal/gf256"
+)
+
+//Option - NewCipher 的可选参数
+type Option func(*AES)
+
+//WithConstantTime - 使用常数时间实现: 支持 AES-NI 时使用 AES-NI，否则S盒由比特切片的 GF(2^8) 求逆和仿射变换计算，
+// 不以秘密数据为下标查表，可抵抗缓存计时攻击，速度比查表实现慢
+func WithConstantTime() Option {
+	return func(aes *AES) {
+		aes.constantTime = true
+	}
+}
+
+/**
+ * S(x) = A·x^-1 + 0x63，A 的第 i 行: b_i ^ b_{i+4} ^ b_{i+5} ^ b_{i+6} ^ b_{i+7}
+ */
+func subBytesCT(block []byte) {
+	for len(block) > 0 {
+		n := len(block)
+		if n > 32 {
+			n = 32
+		}
+		x := gf256.Load(block[:n])
+		x = gf256.Inverse(&x, 0x1b)
+
+		var y gf256.Slice
+		for i := range y {
+			y[i] = x[i] ^ x[(i+4)%8] ^ x[(i+5)%8] ^ x[(i+6)%8] ^ x[(i+7)%8]
+			if 0x63>>uint(i)&1 == 1 {
+				y[i] = ^y[i]
+			}
+		}
+		y.Store(block[:n])
+		block = block[n:]
+	}
+}
+
+/**
+ * S^-1(y) = (A^-1·y + 0x05)^-1，A^-1 的第 i 行: b_{i+2} ^ b_{i+5} ^ b_{i+7}
+ */
+func invSubBytesCT(block []byte) {
+	for len(block) > 0 {
+		n := len(block)
+		if n > 32 {
+			n = 32
+		}
+		y := gf256.Load(block[:n])
+
+		var x gf256.Slice
+		for i := range x {
+			x[i] = y[(i+2)%8] ^ y[(i+5)%8] ^ y[(i+7)%8]
+			if 0x05>>uint(i)&1 == 1 {
+				x[i] = ^x[i]
+			}
+		}
+		x = gf256.Inverse(&x, 0x1b)
+		x.Store(block[:n])
+		block = block[n:]
+	}
+}
+
+/**
+ * 常数时间实现按 FIPS 197 的步骤逐轮计算:
+ * SubBytes 使用比特切片，MixColumns 使用无分支的 xtime
+ */
+func (aes *AES) encryptSteps(dst, src []byte) {
+	var state [BlockSize]byte
+	copy(state[:], src)
+	aes.AddRoundKey(aes.roundKey[:16], state[:])
+	for i := 1; i < aes.nr; i++ {
+		aes.SubBytes(state[:])
+		aes.ShiftRows(state[:])
+		aes.MixColumns(state[:])
+		aes.AddRoundKey(aes.roundKey[i*16:(i+1)*16], state[:])
+	}
+	aes.SubBytes(state[:])
+	aes.ShiftRows(state[:])
+	aes.AddRoundKey(aes.roundKey[aes.nr*16:], state[:])
+	copy(dst, state[:])
+}
+
+func (aes *AES) decryptSteps(dst, src []byte) {
+	var state [BlockSize]byte
+	copy(state[:], src)
+	aes.AddRoundKey(aes.roundKey[aes.nr*16:], state[:])
+	aes.UnShiftRows(state[:])
+	aes.UnSubBytes(state[:])
+	for i := aes.nr - 1; i >= 1; i-- {
+		aes.AddRoundKey(aes.roundKey[i*16:(i+1)*16], state[:])
+		aes.UnMixColumns(state[:])
+		aes.UnShiftRows(state[:])
+		aes.UnSubBytes(state[:])
+	}
+	aes.AddRoundKey(aes.roundKey[:16], state[:])
+	copy(dst, state[:])
+}
@@ -0,0 +1,25 @@
+package aes
+
+import (
+	"github.com/anhk/crypto/keywrap"
+)
+
+//WrapKey - 使用 AES 密钥包装 key (RFC 3394 KW)，len(key) 为 8 的整数倍且不少于 16 字节
+func (aes *AES) WrapKey(key []byte) ([]byte, error) {
+	return keywrap.Wrap(aes, key)
+}
+
+//UnwrapKey - 解包 WrapKey 的结果，完整性校验失败时返回 keywrap.IntegrityError
+func (aes *AES) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return keywrap.Unwrap(aes, wrapped)
+}
+
+//WrapKeyWithPadding - 使用 AES 密钥包装任意长度的 key (RFC 5649 KWP)
+func (aes *AES) WrapKeyWithPadding(key []byte) ([]byte, error) {
+	return keywrap.WrapWithPadding(aes, key)
+}
+
+//UnwrapKeyWithPadding - 解包 WrapKeyWithPadding 的结果，完整性校验失败时返回 keywrap.IntegrityError
+func (aes *AES) UnwrapKeyWithPadding(wrapped []byte) ([]byte, error) {
+	return keywrap.UnwrapWithPadding(aes, wrapped)
+}
@@ -0,0 +1,221 @@
+package ccm
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/anhk/crypto/internal/alias"
+)
+
+const (
+	blockSize = 16
+
+	MinNonceSize = 7
+	MaxNonceSize = 13
+	MinTagSize   = 4
+	MaxTagSize   = 16
+)
+
+var errOpen = errors.New("ccm: message authentication failed")
+
+/**
+ * CCM 工作模式 (RFC 3610, NIST SP 800-38C)
+ * - 随机数长度 N: 7 ~ 13 字节，长度域 L = 15 - N
+ * - 标签长度 M: 4 ~ 16 之间的偶数
+ */
+type ccm struct {
+	b         cipher.Block
+	nonceSize int
+	tagSize   int
+}
+
+//NewCCM - 基于128比特分组密码创建 CCM
+func NewCCM(b cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errors.New("ccm: NewCCM requires 128-bit block cipher")
+	}
+	if nonceSize < MinNonceSize || nonceSize > MaxNonceSize {
+		return nil, errors.New("ccm: invalid nonce size")
+	}
+	if tagSize < MinTagSize || tagSize > MaxTagSize || tagSize%2 != 0 {
+		return nil, errors.New("ccm: invalid tag size")
+	}
+	return &ccm{b: b, nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+func (c *ccm) NonceSize() int {
+	return c.nonceSize
+}
+
+func (c *ccm) Overhead() int {
+	return c.tagSize
+}
+
+/**
+ * 长度域 L 字节能表示的最大消息长度 2^(8L) - 1
+ */
+func (c *ccm) maxLength() uint64 {
+	l := uint(15 - c.nonceSize)
+	if l >= 8 {
+		return math.MaxUint64
+	}
+	return 1<<(8*l) - 1
+}
+
+/**
+ * 计数器块 Ai = Flags(L-1) || N || i
+ */
+func (c *ccm) counter(nonce []byte) [blockSize]byte {
+	var ctr [blockSize]byte
+	ctr[0] = byte(15 - c.nonceSize - 1)
+	copy(ctr[1:], nonce)
+	return ctr
+}
+
+func incCounter(ctr *[blockSize]byte) {
+	for i := blockSize - 1; i >= 0; i-- {
+		ctr[i]++
+		if ctr[i] != 0 {
+			break
+		}
+	}
+}
+
+/**
+ * 以 A1, A2, ... 为计数器进行CTR模式加解密
+ */
+func (c *ccm) counterCrypt(out, in []byte, ctr [blockSize]byte) {
+	var mask [blockSize]byte
+	for len(in) > 0 {
+		incCounter(&ctr)
+		c.b.Encrypt(mask[:], ctr[:])
+		n := len(in)
+		if n > blockSize {
+			n = blockSize
+		}
+		for i := 0; i < n; i++ {
+			out[i] = in[i] ^ mask[i]
+		}
+		out, in = out[n:], in[n:]
+	}
+}
+
+/**
+ * CBC-MAC
+ * - B0 = Flags || N || Q，Flags = 64*Adata + 8*((M-2)/2) + (L-1)
+ * - 附加数据前缀其长度编码，之后补0至整块
+ * - 明文补0至整块
+ * T = CBC-MAC(B0 || AAD || P)，tag = T ^ S0
+ */
+func (c *ccm) auth(nonce, plaintext, additionalData []byte, tag *[blockSize]byte) {
+	var x [blockSize]byte
+	l := 15 - c.nonceSize
+
+	x[0] = byte(8*((c.tagSize-2)/2) + (l - 1))
+	if len(additionalData) > 0 {
+		x[0] |= 64
+	}
+	copy(x[1:], nonce)
+	q := uint64(len(plaintext))
+	for i := blockSize - 1; i > c.nonceSize; i-- {
+		x[i] = byte(q)
+		q >>= 8
+	}
+	c.b.Encrypt(x[:], x[:])
+
+	if n := uint64(len(additionalData)); n > 0 {
+		var prefix []byte
+		switch {
+		case n < 0xFF00:
+			prefix = []byte{byte(n >> 8), byte(n)}
+		case n <= math.MaxUint32:
+			prefix = make([]byte, 6)
+			prefix[0], prefix[1] = 0xFF, 0xFE
+			binary.BigEndian.PutUint32(prefix[2:], uint32(n))
+		default:
+			prefix = make([]byte, 10)
+			prefix[0], prefix[1] = 0xFF, 0xFF
+			binary.BigEndian.PutUint64(prefix[2:], n)
+		}
+		c.cbcMAC(&x, append(prefix, additionalData...))
+	}
+	c.cbcMAC(&x, plaintext)
+
+	ctr := c.counter(nonce)
+	c.b.Encrypt(tag[:], ctr[:])
+	for i := range tag {
+		tag[i] ^= x[i]
+	}
+}
+
+/**
+ * 将数据按块异或进 CBC-MAC 状态，最后一块不足时补0
+ */
+func (c *ccm) cbcMAC(x *[blockSize]byte, data []byte) {
+	for len(data) > 0 {
+		n := len(data)
+		if n > blockSize {
+			n = blockSize
+		}
+		for i := 0; i < n; i++ {
+			x[i] ^= data[i]
+		}
+		c.b.Encrypt(x[:], x[:])
+		data = data[n:]
+	}
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > c.maxLength() {
+		panic("ccm: message too large for CCM")
+	}
+
+	ret, out := alias.SliceForAppend(dst, len(plaintext)+c.tagSize)
+	if alias.InexactOverlap(out, plaintext) {
+		panic("ccm: invalid buffer overlap")
+	}
+
+	var tag [blockSize]byte
+	c.auth(nonce, plaintext, additionalData, &tag)
+	c.counterCrypt(out, plaintext, c.counter(nonce))
+	copy(out[len(plaintext):], tag[:c.tagSize])
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("ccm: incorrect nonce length given to CCM")
+	}
+	if len(ciphertext) < c.tagSize {
+		return nil, errOpen
+	}
+	if uint64(len(ciphertext)-c.tagSize) > c.maxLength() {
+		return nil, errOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-c.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	ret, out := alias.SliceForAppend(dst, len(ciphertext))
+	if alias.InexactOverlap(out, ciphertext) {
+		panic("ccm: invalid buffer overlap")
+	}
+
+	c.counterCrypt(out, ciphertext, c.counter(nonce))
+
+	var expectedTag [blockSize]byte
+	c.auth(nonce, out, additionalData, &expectedTag)
+	if subtle.ConstantTimeCompare(expectedTag[:c.tagSize], tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+	return ret, nil
+}
@@ -0,0 +1,106 @@
+package ccm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var ccmTests = []struct {
+	key, nonce, aad, plain, cipher string
+	tagSize                        int
+}{
+	/**
+	 * RFC 3610 Packet Vector #1 ~ #3
+	 */
+	{
+		"C0C1C2C3C4C5C6C7C8C9CACBCCCDCECF", "00000003020100A0A1A2A3A4A5", "0001020304050607",
+		"08090A0B0C0D0E0F101112131415161718191A1B1C1D1E",
+		"588C979A61C663D2F066D0C2C0F989806D5F6B61DAC38417E8D12CFDF926E0", 8,
+	},
+	{
+		"C0C1C2C3C4C5C6C7C8C9CACBCCCDCECF", "00000004030201A0A1A2A3A4A5", "0001020304050607",
+		"08090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+		"72C91A36E135F8CF291CA894085C87E3CC15C439C9E43A3BA091D56E10400916", 8,
+	},
+	{
+		"C0C1C2C3C4C5C6C7C8C9CACBCCCDCECF", "00000005040302A0A1A2A3A4A5", "0001020304050607",
+		"08090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F20",
+		"51B1E5F44A197D1DA46B0F8E2D282AE871E838BB64DA8596574ADAA76FBD9FB0C5", 8,
+	},
+	/**
+	 * NIST SP 800-38C 附录 C Example 1 ~ 2
+	 */
+	{
+		"404142434445464748494a4b4c4d4e4f", "10111213141516", "0001020304050607",
+		"20212223", "7162015b4dac255d", 4,
+	},
+	{
+		"404142434445464748494a4b4c4d4e4f", "1011121314151617", "000102030405060708090a0b0c0d0e0f",
+		"202122232425262728292a2b2c2d2e2f", "d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd", 6,
+	},
+}
+
+func TestCCM(t *testing.T) {
+	for i, test := range ccmTests {
+		block, _ := aes.NewCipher(mustHex(test.key))
+		nonce := mustHex(test.nonce)
+		c, err := NewCCM(block, len(nonce), test.tagSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		aad, plain, expected := mustHex(test.aad), mustHex(test.plain), mustHex(test.cipher)
+		ct := c.Seal(nil, nonce, plain, aad)
+		if !bytes.Equal(ct, expected) {
+			t.Fatalf("#%d: invalid seal %x", i, ct)
+		}
+
+		pt, err := c.Open(nil, nonce, ct, aad)
+		if err != nil || !bytes.Equal(pt, plain) {
+			t.Fatalf("#%d: invalid open", i)
+		}
+
+		ct[len(ct)-1] ^= 1
+		if _, err := c.Open(nil, nonce, ct, aad); err != errOpen {
+			t.Fatalf("#%d: open succeeded with a modified tag", i)
+		}
+	}
+}
+
+func TestCCMSizes(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	plain := bytes.Repeat([]byte{0x5A}, 70)
+	for nonceSize := MinNonceSize; nonceSize <= MaxNonceSize; nonceSize++ {
+		for tagSize := MinTagSize; tagSize <= MaxTagSize; tagSize += 2 {
+			c, err := NewCCM(block, nonceSize, tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := make([]byte, nonceSize)
+			ct := c.Seal(nil, nonce, plain, nil)
+			if len(ct) != len(plain)+tagSize {
+				t.Fatal("invalid cipher text length")
+			}
+			pt, err := c.Open(ct[:0], nonce, ct, nil)
+			if err != nil || !bytes.Equal(pt, plain) {
+				t.Fatalf("nonce %d, tag %d: invalid open", nonceSize, tagSize)
+			}
+		}
+	}
+
+	for _, size := range [][2]int{{6, 8}, {14, 8}, {12, 2}, {12, 5}, {12, 18}} {
+		if _, err := NewCCM(block, size[0], size[1]); err == nil {
+			t.Fatalf("nonce %d, tag %d should fail", size[0], size[1])
+		}
+	}
+}
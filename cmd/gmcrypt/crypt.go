@@ -0,0 +1,294 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/envelope"
+	"github.com/anhk/crypto/padding"
+	"github.com/anhk/crypto/sm4"
+)
+
+/**
+ * 每次读写的数据量，为分组长度的整数倍
+ */
+const chunkSize = 32 * 1024
+
+type cryptOptions struct {
+	alg     string
+	mode    string
+	key     string
+	keyFile string
+	iv      string
+	in      string
+	out     string
+}
+
+/**
+ * enc/dec
+ * - cbc (PKCS#7 填充), ctr, cfb, ofb: 流式处理；未指定 -iv 时加密随机生成 IV 并写在输出开头，
+ *   解密从输入开头读取 IV
+ * - gcm: 分块流式加密格式 (envelope.NewWriter)，解密时只输出通过认证的分块，
+ *   输出到标准输出时，出错前已输出的部分是原文的前缀
+ * - ccm: 输出 envelope 格式(随机数包含在头部)，需要将整个输入读入内存
+ *
+ * 参数在打开输出之前全部检查；输出到文件时出错(如填充错误、认证失败)不会修改已有的文件
+ */
+func crypt(encrypt bool, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	name := "dec"
+	if encrypt {
+		name = "enc"
+	}
+
+	var opts cryptOptions
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.alg, "alg", "sm4", "algorithm: sm4 or aes")
+	fs.StringVar(&opts.mode, "mode", "cbc", "mode: cbc, ctr, cfb, ofb, gcm or ccm")
+	fs.StringVar(&opts.key, "key", "", "key in hex")
+	fs.StringVar(&opts.keyFile, "keyfile", "", "file containing the key in hex, as written by keygen")
+	fs.StringVar(&opts.iv, "iv", "", "IV in hex (cbc, ctr, cfb, ofb); random and stored with the output if omitted")
+	fs.StringVar(&opts.in, "in", "-", "input file")
+	fs.StringVar(&opts.out, "out", "-", "output file")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(stderr, "gmcrypt %s: unexpected argument %q\n", name, fs.Arg(0))
+		return errUsage
+	}
+
+	key, err := loadKey(opts.key, opts.keyFile)
+	if err != nil {
+		return err
+	}
+	block, err := newBlock(opts.alg, key)
+	if err != nil {
+		return err
+	}
+
+	var iv []byte
+	switch opts.mode {
+	case "gcm", "ccm":
+		if opts.iv != "" {
+			return fmt.Errorf("-iv is not used with %s", opts.mode)
+		}
+	case "cbc", "ctr", "cfb", "ofb":
+		if opts.iv != "" {
+			if iv, err = hex.DecodeString(opts.iv); err != nil || len(iv) != block.BlockSize() {
+				return fmt.Errorf("IV must be %d bytes in hex", block.BlockSize())
+			}
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", opts.mode)
+	}
+
+	in, err := openInput(opts.in, stdin)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := createOutput(opts.out, stdout, 0644)
+	if err != nil {
+		return err
+	}
+
+	switch opts.mode {
+	case "gcm", "ccm":
+		err = cryptEnvelope(encrypt, opts.alg, opts.mode, key, out, in)
+	default:
+		err = cryptStream(encrypt, opts.mode, block, iv, out, in)
+	}
+	if err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Commit()
+}
+
+/**
+ * -key 与 -keyfile 只能指定一个
+ */
+func loadKey(keyHex, keyFile string) ([]byte, error) {
+	if (keyHex == "") == (keyFile == "") {
+		return nil, errors.New("exactly one of -key and -keyfile is required")
+	}
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		keyHex = strings.TrimSpace(string(data))
+	}
+
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, errors.New("invalid hex key")
+	}
+	return key, nil
+}
+
+func newBlock(alg string, key []byte) (cipher.Block, error) {
+	switch alg {
+	case "sm4":
+		return sm4.NewCipher(key)
+	case "aes":
+		return aes.NewCipher(key)
+	}
+	return nil, fmt.Errorf("unknown algorithm %q", alg)
+}
+
+func cryptEnvelope(encrypt bool, alg, mode string, key []byte, out io.Writer, in io.Reader) error {
+	var k envelope.Key = envelope.SM4Key(key)
+	if alg == "aes" {
+		k = envelope.AESKey(key)
+	}
+
+	if mode == "gcm" {
+		return cryptChunked(encrypt, k, out, in)
+	}
+
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if encrypt {
+		data, err = envelope.SealWithMode(k, envelope.ModeCCM, data, nil)
+	} else {
+		data, err = envelope.Open(k, data, nil)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+func cryptChunked(encrypt bool, key envelope.Key, out io.Writer, in io.Reader) error {
+	buf := make([]byte, chunkSize)
+	if !encrypt {
+		r, err := envelope.NewReader(in, key)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyBuffer(out, r, buf)
+		return err
+	}
+
+	w, err := envelope.NewWriter(out, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyBuffer(w, in, buf); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+/**
+ * iv 为 nil 时加密随机生成 IV 并写在输出开头，解密从输入开头读取
+ */
+func cryptStream(encrypt bool, mode string, block cipher.Block, iv []byte, out io.Writer, in io.Reader) error {
+	if iv == nil {
+		iv = make([]byte, block.BlockSize())
+		if encrypt {
+			if _, err := io.ReadFull(randReader, iv); err != nil {
+				return err
+			}
+			if _, err := out.Write(iv); err != nil {
+				return err
+			}
+		} else if _, err := io.ReadFull(in, iv); err != nil {
+			return errors.New("input too short to contain IV")
+		}
+	}
+
+	var stream cipher.Stream
+	switch mode {
+	case "cbc":
+		if encrypt {
+			return encryptCBC(cipher.NewCBCEncrypter(block, iv), out, in)
+		}
+		return decryptCBC(cipher.NewCBCDecrypter(block, iv), out, in)
+	case "ctr":
+		stream = cipher.NewCTR(block, iv)
+	case "cfb":
+		if encrypt {
+			stream = cipher.NewCFBEncrypter(block, iv)
+		} else {
+			stream = cipher.NewCFBDecrypter(block, iv)
+		}
+	case "ofb":
+		stream = cipher.NewOFB(block, iv)
+	}
+
+	_, err := io.CopyBuffer(cipher.StreamWriter{S: stream, W: out}, in, make([]byte, chunkSize))
+	return err
+}
+
+/**
+ * 整块直接加密输出，读到结尾时对剩余数据做 PKCS#7 填充
+ */
+func encryptCBC(bm cipher.BlockMode, out io.Writer, in io.Reader) error {
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(in, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			last := padding.PKCS7.Pad(buf[:n], bm.BlockSize())
+			bm.CryptBlocks(last, last)
+			_, err = out.Write(last)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		bm.CryptBlocks(buf, buf)
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+/**
+ * 保留最后一个分组，直到确认其后没有数据时才去除填充
+ */
+func decryptCBC(bm cipher.BlockMode, out io.Writer, in io.Reader) error {
+	bs := bm.BlockSize()
+	buf := make([]byte, chunkSize)
+	held := 0
+	for {
+		n, err := io.ReadFull(in, buf[held:])
+		n += held
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if n == 0 || n%bs != 0 {
+				return errors.New("ciphertext is not a multiple of the block size")
+			}
+			bm.CryptBlocks(buf[:n], buf[:n])
+			plain, err := padding.PKCS7.Unpad(buf[:n], bs)
+			if err != nil {
+				return err
+			}
+			_, err = out.Write(plain)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		k := n - bs
+		bm.CryptBlocks(buf[:k], buf[:k])
+		if _, err := out.Write(buf[:k]); err != nil {
+			return err
+		}
+		held = copy(buf, buf[k:n])
+	}
+}
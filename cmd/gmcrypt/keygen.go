@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+)
+
+/**
+ * 生成随机密钥，以十六进制输出，可直接作为 -keyfile 使用
+ * 写入文件时权限为 0600
+ */
+func keygen(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	alg := fs.String("alg", "sm4", "algorithm: sm4 or aes")
+	size := fs.Int("size", 16, "key size in bytes (aes: 16, 24 or 32)")
+	outName := fs.String("out", "-", "output file")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if *size <= 0 {
+		fmt.Fprintf(stderr, "gmcrypt keygen: invalid key size %d\n", *size)
+		return errUsage
+	}
+
+	key := make([]byte, *size)
+	if _, err := newBlock(*alg, key); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(randReader, key); err != nil {
+		return err
+	}
+
+	out, err := createOutput(*outName, stdout, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out, hex.EncodeToString(key)); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Commit()
+}
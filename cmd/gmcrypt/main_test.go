@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+const (
+	testKey = "0123456789abcdeffedcba9876543210"
+	testIV  = "000102030405060708090a0b0c0d0e0f"
+)
+
+type result struct {
+	code           int
+	stdout, stderr string
+}
+
+func runCmd(stdin []byte, args ...string) result {
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr)
+	return result{code, stdout.String(), stderr.String()}
+}
+
+/**
+ * 与 testdata 中的期望输出比较，-update 时重写
+ */
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("%s: output mismatch\ngot:  %x\nwant: %x", name, got, expected)
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestUsage(t *testing.T) {
+	if r := runCmd(nil); r.code != exitUsage {
+		t.Fatal("no command should be a usage error")
+	}
+	if r := runCmd(nil, "frobnicate"); r.code != exitUsage || !strings.Contains(r.stderr, "unknown command") {
+		t.Fatal("unknown command should be a usage error")
+	}
+	if r := runCmd(nil, "enc", "-nosuchflag"); r.code != exitUsage {
+		t.Fatal("unknown flag should be a usage error")
+	}
+	if r := runCmd(nil, "help"); r.code != exitOK || !strings.Contains(r.stdout, "sm3sum") {
+		t.Fatal("help should print usage")
+	}
+}
+
+/**
+ * testdata/sm3sum.golden 与 `openssl dgst -sm3` 的结果一致
+ */
+func TestSM3Sum(t *testing.T) {
+	r := runCmd(nil, "sm3sum", "testdata/hello.txt", "testdata/empty.txt")
+	if r.code != exitOK {
+		t.Fatal(r.stderr)
+	}
+	checkGolden(t, "sm3sum", []byte(r.stdout))
+
+	r = runCmd(readFile(t, "hello.txt"), "sm3sum")
+	if !strings.HasSuffix(r.stdout, "  -\n") || r.stdout[:64] != strings.SplitN(string(readFile(t, "sm3sum.golden")), " ", 2)[0] {
+		t.Fatal("stdin checksum mismatch:", r.stdout)
+	}
+
+	if r := runCmd(nil, "sm3sum", "testdata/missing.txt"); r.code != exitError || r.stderr == "" {
+		t.Fatal("missing file should fail")
+	}
+}
+
+func TestSM3SumCheck(t *testing.T) {
+	r := runCmd(nil, "sm3sum", "-c", "testdata/sm3sum.golden")
+	if r.code != exitOK || r.stdout != "testdata/hello.txt: OK\ntestdata/empty.txt: OK\n" {
+		t.Fatal("check failed:", r.stdout, r.stderr)
+	}
+
+	/**
+	 * 清单从标准输入读取，含二进制模式标记与格式错误的行
+	 */
+	list := readFile(t, "sm3sum.golden")
+	bad := strings.Replace(string(list), "  testdata/empty.txt", " *testdata/hello.txt", 1) + "not a checksum line\n"
+	r = runCmd([]byte(bad), "sm3sum", "-c")
+	if r.code != exitError {
+		t.Fatal("mismatch should fail")
+	}
+	checkGolden(t, "sm3sum-check", []byte(r.stdout+r.stderr))
+}
+
+/**
+ * 期望密文与 `openssl enc -sm4-cbc/-aes-128-ctr ... -K -iv` 的结果一致
+ */
+func TestEncrypt(t *testing.T) {
+	plain := readFile(t, "hello.txt")
+	for _, alg := range []string{"sm4", "aes"} {
+		for _, mode := range []string{"cbc", "ctr", "cfb", "ofb"} {
+			name := alg + "-" + mode
+			r := runCmd(plain, "enc", "-alg", alg, "-mode", mode, "-key", testKey, "-iv", testIV)
+			if r.code != exitOK {
+				t.Fatal(name, r.stderr)
+			}
+			checkGolden(t, name, []byte(r.stdout))
+
+			r = runCmd([]byte(r.stdout), "dec", "-alg", alg, "-mode", mode, "-key", testKey, "-iv", testIV)
+			if r.code != exitOK || r.stdout != string(plain) {
+				t.Fatal(name, "invalid decrypt", r.stderr)
+			}
+		}
+	}
+}
+
+/**
+ * 未指定 IV 时随机生成并写在密文开头；跨越多个读缓冲区的数据
+ */
+func TestRandomIV(t *testing.T) {
+	plain := bytes.Repeat([]byte("0123456789abcdef"), 3*chunkSize/16)
+	for _, n := range []int{0, 15, 16, chunkSize - 16, chunkSize, chunkSize + 1, 2*chunkSize + 5} {
+		for _, mode := range []string{"cbc", "ctr", "cfb", "ofb"} {
+			r := runCmd(plain[:n], "enc", "-mode", mode, "-key", testKey)
+			if r.code != exitOK {
+				t.Fatal(r.stderr)
+			}
+			if mode == "cbc" && len(r.stdout) != 16+(n/16+1)*16 {
+				t.Fatal("cbc length", n, len(r.stdout))
+			}
+
+			r = runCmd([]byte(r.stdout), "dec", "-mode", mode, "-key", testKey)
+			if r.code != exitOK || r.stdout != string(plain[:n]) {
+				t.Fatal(mode, "round trip failed, length", n, r.stderr)
+			}
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	plain := readFile(t, "hello.txt")
+	for _, alg := range []string{"sm4", "aes"} {
+		for _, mode := range []string{"gcm", "ccm"} {
+			r := runCmd(plain, "enc", "-alg", alg, "-mode", mode, "-key", testKey)
+			if r.code != exitOK {
+				t.Fatal(r.stderr)
+			}
+			ct := []byte(r.stdout)
+
+			r = runCmd(ct, "dec", "-alg", alg, "-mode", mode, "-key", testKey)
+			if r.code != exitOK || r.stdout != string(plain) {
+				t.Fatal(alg, mode, "round trip failed", r.stderr)
+			}
+
+			ct[len(ct)-1] ^= 1
+			if r := runCmd(ct, "dec", "-alg", alg, "-mode", mode, "-key", testKey); r.code != exitError || r.stdout != "" {
+				t.Fatal(alg, mode, "tampered ciphertext accepted")
+			}
+		}
+	}
+
+	if r := runCmd(plain, "enc", "-mode", "gcm", "-key", testKey, "-iv", testIV); r.code != exitError {
+		t.Fatal("-iv with gcm should fail")
+	}
+}
+
+func TestKeygen(t *testing.T) {
+	defer func(saved io.Reader) { randReader = saved }(randReader)
+	randReader = bytes.NewReader(bytes.Repeat([]byte{0xA5, 0x5A}, 16))
+
+	r := runCmd(nil, "keygen", "-alg", "aes", "-size", "32")
+	if r.code != exitOK {
+		t.Fatal(r.stderr)
+	}
+	checkGolden(t, "keygen", []byte(r.stdout))
+
+	if r := runCmd(nil, "keygen", "-alg", "sm4", "-size", "32"); r.code != exitError {
+		t.Fatal("invalid sm4 key size should fail")
+	}
+	for _, size := range []string{"-1", "0"} {
+		if r := runCmd(nil, "keygen", "-size", size); r.code != exitUsage || !strings.Contains(r.stderr, "invalid key size") {
+			t.Fatal("key size", size, "should be a usage error")
+		}
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	plain := readFile(t, "hello.txt")
+	r := runCmd(plain, "enc", "-keyfile", "testdata/sm4.key", "-iv", testIV)
+	if r.code != exitOK {
+		t.Fatal(r.stderr)
+	}
+	checkGolden(t, "sm4-cbc", []byte(r.stdout))
+
+	if r := runCmd(plain, "enc", "-key", testKey, "-keyfile", "testdata/sm4.key"); r.code != exitError {
+		t.Fatal("both -key and -keyfile should fail")
+	}
+	if r := runCmd(plain, "enc"); r.code != exitError {
+		t.Fatal("missing key should fail")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	ct := readFile(t, "sm4-cbc.golden")
+	tests := []struct {
+		name  string
+		input []byte
+		args  []string
+	}{
+		{"wrong key", ct, []string{"-key", "00" + testKey[2:], "-iv", testIV}},
+		{"truncated", ct[:len(ct)-1], []string{"-key", testKey, "-iv", testIV}},
+		{"no iv", ct[:8], []string{"-key", testKey}},
+		{"bad key size", ct, []string{"-key", "0011", "-iv", testIV}},
+		{"bad iv", ct, []string{"-key", testKey, "-iv", "0011"}},
+		{"bad mode", ct, []string{"-key", testKey, "-mode", "ecb"}},
+		{"bad alg", ct, []string{"-key", testKey, "-alg", "des"}},
+	}
+	for _, test := range tests {
+		r := runCmd(test.input, append([]string{"dec"}, test.args...)...)
+		if r.code != exitError || !strings.HasPrefix(r.stderr, "gmcrypt: ") {
+			t.Fatal(test.name, "should fail, got", r.code, r.stderr)
+		}
+	}
+}
+
+/**
+ * 出错时不修改已有的输出文件，也不留下临时文件
+ */
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmcrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	if err := ioutil.WriteFile(out, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ct := readFile(t, "sm4-cbc.golden")
+	gcm := runCmd(readFile(t, "hello.txt"), "enc", "-mode", "gcm", "-key", testKey)
+	tampered := []byte(gcm.stdout)
+	tampered[len(tampered)-1] ^= 1
+	failures := []struct {
+		name  string
+		input []byte
+		args  []string
+	}{
+		{"bad mode", ct, []string{"-mode", "ecb", "-key", testKey}},
+		{"bad alg", ct, []string{"-alg", "des", "-key", testKey}},
+		{"bad key size", ct, []string{"-key", "0011"}},
+		{"bad iv", ct, []string{"-key", testKey, "-iv", "0011"}},
+		{"cbc unpad", ct, []string{"-key", "00" + testKey[2:], "-iv", testIV}},
+		{"gcm auth", tampered, []string{"-mode", "gcm", "-key", testKey}},
+	}
+	for _, test := range failures {
+		r := runCmd(test.input, append([]string{"dec", "-out", out}, test.args...)...)
+		if r.code != exitError {
+			t.Fatal(test.name, "should fail")
+		}
+		if data, _ := ioutil.ReadFile(out); string(data) != "existing" {
+			t.Fatal(test.name, "modified the output file:", string(data))
+		}
+	}
+
+	r := runCmd(ct, "dec", "-key", testKey, "-iv", testIV, "-out", out)
+	if r.code != exitOK || r.stdout != "" {
+		t.Fatal(r.stderr)
+	}
+	if data, _ := ioutil.ReadFile(out); !bytes.Equal(data, readFile(t, "hello.txt")) {
+		t.Fatal("output file not replaced")
+	}
+
+	if r := runCmd(nil, "keygen", "-out", filepath.Join(dir, "key")); r.code != exitOK {
+		t.Fatal(r.stderr)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "key")); err != nil || fi.Mode().Perm()&0077 != 0 {
+		t.Fatal("invalid key file", err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Fatal("temporary files left behind:", len(files))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/anhk/crypto/sm3"
+)
+
+/**
+ * 输出格式与 sha256sum 相同: "<64位十六进制>  <文件名>"
+ * -c 时读取该格式的清单逐个校验，输出 "<文件名>: OK" 或 "<文件名>: FAILED"
+ * 返回值 ok 表示全部文件均可读且校验通过
+ */
+func sm3sum(args []string, stdin io.Reader, stdout, stderr io.Writer) (ok bool, err error) {
+	fs := flag.NewFlagSet("sm3sum", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	check := fs.Bool("c", false, "read SM3 sums from the files and check them")
+	if err := fs.Parse(args); err != nil {
+		return false, errUsage
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	ok = true
+	for _, name := range files {
+		var good bool
+		if *check {
+			good, err = checkSums(name, stdin, stdout, stderr)
+		} else {
+			good, err = printSum(name, stdin, stdout, stderr)
+		}
+		if err != nil {
+			return false, err
+		}
+		ok = ok && good
+	}
+	return ok, nil
+}
+
+func fileSum(name string, stdin io.Reader) ([]byte, error) {
+	f, err := openInput(name, stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sm3.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+/**
+ * 无法读取的文件报告到 stderr 并继续处理其余文件
+ */
+func printSum(name string, stdin io.Reader, stdout, stderr io.Writer) (bool, error) {
+	sum, err := fileSum(name, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, "gmcrypt:", err)
+		return false, nil
+	}
+	_, err = fmt.Fprintf(stdout, "%x  %s\n", sum, name)
+	return true, err
+}
+
+func checkSums(list string, stdin io.Reader, stdout, stderr io.Writer) (bool, error) {
+	f, err := openInput(list, stdin)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var malformed, unreadable, mismatched int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		expected, name, ok := parseSumLine(line)
+		if !ok {
+			malformed++
+			continue
+		}
+
+		sum, err := fileSum(name, stdin)
+		switch {
+		case err != nil:
+			fmt.Fprintln(stderr, "gmcrypt:", err)
+			fmt.Fprintf(stdout, "%s: FAILED open or read\n", name)
+			unreadable++
+		case hex.EncodeToString(sum) != expected:
+			fmt.Fprintf(stdout, "%s: FAILED\n", name)
+			mismatched++
+		default:
+			fmt.Fprintf(stdout, "%s: OK\n", name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	if malformed > 0 {
+		fmt.Fprintf(stderr, "gmcrypt: WARNING: %d line(s) improperly formatted\n", malformed)
+	}
+	if unreadable > 0 {
+		fmt.Fprintf(stderr, "gmcrypt: WARNING: %d listed file(s) could not be read\n", unreadable)
+	}
+	if mismatched > 0 {
+		fmt.Fprintf(stderr, "gmcrypt: WARNING: %d computed checksum(s) did NOT match\n", mismatched)
+	}
+	return malformed+unreadable+mismatched == 0, nil
+}
+
+/**
+ * 解析 "<sum>  <name>" 或二进制模式 "<sum> *<name>"
+ */
+func parseSumLine(line string) (sum, name string, ok bool) {
+	const sumLen = 2 * sm3.DigestLength
+	if len(line) < sumLen+3 || line[sumLen] != ' ' || (line[sumLen+1] != ' ' && line[sumLen+1] != '*') {
+		return "", "", false
+	}
+	sum = strings.ToLower(line[:sumLen])
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", false
+	}
+	return sum, line[sumLen+2:], true
+}
@@ -0,0 +1,170 @@
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/kdf"
+	"github.com/anhk/crypto/sm4"
+)
+
+/**
+ * 信封格式 (version 1)
+ *
+ *   +-------+---------+-----------+------+-----------+-------+-------------------+
+ *   | magic | version | algorithm | mode | nonce len | nonce | ciphertext || tag |
+ *   | "GM"  |  1 byte |   1 byte  | 1 B  |   1 byte  |  ...  |        ...        |
+ *   +-------+---------+-----------+------+-----------+-------+-------------------+
+ *
+ * 头部(magic ~ nonce)与调用者的附加数据一起作为AEAD的附加数据，
+ * 修改头部中的任何字段都会导致解密失败
+ */
+const (
+	Version1 = 1
+
+	headerSize = 6
+	tagSize    = 16
+	nonceSize  = 12
+)
+
+var magic = [2]byte{'G', 'M'}
+
+//Algorithm - 分组密码算法
+type Algorithm byte
+
+const (
+	AlgorithmSM4 Algorithm = 1
+	AlgorithmAES Algorithm = 2
+)
+
+//Mode - AEAD 工作模式
+type Mode byte
+
+const (
+	ModeGCM Mode = 1
+	ModeCCM Mode = 2
+)
+
+var (
+	ErrInvalidHeader      = errors.New("envelope: invalid header")
+	ErrUnsupportedVersion = errors.New("envelope: unsupported version")
+	ErrAlgorithmMismatch  = errors.New("envelope: key does not match algorithm")
+	ErrOpen               = errors.New("envelope: message authentication failed")
+)
+
+//Key - 密钥的类型决定使用的算法，只能是 SM4Key 或 AESKey
+type Key interface {
+	algorithm() Algorithm
+	newAEAD(mode Mode) (cipher.AEAD, error)
+	derive(salt, info []byte) (Key, error)
+}
+
+//SM4Key - 16字节 SM4 密钥
+type SM4Key []byte
+
+//AESKey - 16、24 或 32 字节 AES 密钥
+type AESKey []byte
+
+func (k SM4Key) algorithm() Algorithm {
+	return AlgorithmSM4
+}
+
+func (k SM4Key) newAEAD(mode Mode) (cipher.AEAD, error) {
+	switch mode {
+	case ModeGCM:
+		return sm4.NewGCM(k)
+	case ModeCCM:
+		return sm4.NewCCM(k, nonceSize, tagSize)
+	}
+	return nil, ErrInvalidHeader
+}
+
+func (k SM4Key) derive(salt, info []byte) (Key, error) {
+	b, err := kdf.HKDF(nil, k, salt, info, len(k))
+	return SM4Key(b), err
+}
+
+func (k AESKey) algorithm() Algorithm {
+	return AlgorithmAES
+}
+
+func (k AESKey) newAEAD(mode Mode) (cipher.AEAD, error) {
+	switch mode {
+	case ModeGCM:
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ModeCCM:
+		return aes.NewCCM(k, nonceSize, tagSize)
+	}
+	return nil, ErrInvalidHeader
+}
+
+func (k AESKey) derive(salt, info []byte) (Key, error) {
+	b, err := kdf.HKDF(nil, k, salt, info, len(k))
+	return AESKey(b), err
+}
+
+//Seal - 使用 GCM 模式和随机生成的随机数加密，返回带头部的信封
+func Seal(key Key, plaintext, additionalData []byte) ([]byte, error) {
+	return SealWithMode(key, ModeGCM, plaintext, additionalData)
+}
+
+//SealWithMode - 使用指定的 AEAD 模式加密
+func SealWithMode(key Key, mode Mode, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := key.newAEAD(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, headerSize+nonceSize, headerSize+nonceSize+len(plaintext)+aead.Overhead())
+	copy(out, magic[:])
+	out[2] = Version1
+	out[3] = byte(key.algorithm())
+	out[4] = byte(mode)
+	out[5] = nonceSize
+
+	nonce := out[headerSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := out[:headerSize+nonceSize]
+	return aead.Seal(out, nonce, plaintext, append(header[:len(header):len(header)], additionalData...)), nil
+}
+
+//Open - 解析信封头部并解密，key 的类型必须与头部记录的算法一致
+func Open(key Key, envelope, additionalData []byte) ([]byte, error) {
+	if len(envelope) < headerSize || envelope[0] != magic[0] || envelope[1] != magic[1] {
+		return nil, ErrInvalidHeader
+	}
+	if envelope[2] != Version1 {
+		return nil, ErrUnsupportedVersion
+	}
+	if Algorithm(envelope[3]) != key.algorithm() {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	aead, err := key.newAEAD(Mode(envelope[4]))
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(envelope[5])
+	if n != aead.NonceSize() || len(envelope) < headerSize+n+aead.Overhead() {
+		return nil, ErrInvalidHeader
+	}
+
+	header := envelope[:headerSize+n]
+	nonce := envelope[headerSize : headerSize+n]
+	plaintext, err := aead.Open(nil, nonce, envelope[headerSize+n:], append(header[:len(header):len(header)], additionalData...))
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,316 @@
+package envelope
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+var (
+	sm4Key = SM4Key{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10}
+	aesKey = AESKey{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
+)
+
+func TestSealOpen(t *testing.T) {
+	plaintext := []byte("hello, world! hello, world! hello, world!")
+	aad := []byte("header")
+
+	for _, key := range []Key{sm4Key, aesKey} {
+		for _, mode := range []Mode{ModeGCM, ModeCCM} {
+			for _, pt := range [][]byte{nil, plaintext} {
+				blob, err := SealWithMode(key, mode, pt, aad)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(blob) != headerSize+nonceSize+len(pt)+tagSize {
+					t.Fatal("unexpected envelope length", len(blob))
+				}
+				if blob[0] != 'G' || blob[1] != 'M' || blob[2] != Version1 ||
+					Algorithm(blob[3]) != key.algorithm() || Mode(blob[4]) != mode || blob[5] != nonceSize {
+					t.Fatalf("unexpected header %x", blob[:headerSize])
+				}
+
+				out, err := Open(key, blob, aad)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(out, pt) {
+					t.Fatal("plaintext mismatch")
+				}
+			}
+		}
+	}
+}
+
+func TestSealRandomNonce(t *testing.T) {
+	a, _ := Seal(sm4Key, []byte("message"), nil)
+	b, _ := Seal(sm4Key, []byte("message"), nil)
+	if bytes.Equal(a, b) {
+		t.Fatal("two envelopes share the same nonce")
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	aad := []byte("header")
+	blob, err := Seal(sm4Key, []byte("message"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/**
+	 * 修改 nonce、密文或标签中的任一字节
+	 */
+	for i := headerSize; i < len(blob); i++ {
+		bad := append([]byte{}, blob...)
+		bad[i] ^= 1
+		if _, err := Open(sm4Key, bad, aad); err != ErrOpen {
+			t.Fatal("tampered byte", i, "not detected:", err)
+		}
+	}
+
+	if _, err := Open(sm4Key, blob, []byte("other")); err != ErrOpen {
+		t.Fatal("wrong additional data not detected:", err)
+	}
+	if _, err := Open(SM4Key(aesKey), blob, aad); err != ErrOpen {
+		t.Fatal("wrong key not detected:", err)
+	}
+
+	/**
+	 * 将 GCM 改为 CCM，头部参与认证
+	 */
+	bad := append([]byte{}, blob...)
+	bad[4] = byte(ModeCCM)
+	if _, err := Open(sm4Key, bad, aad); err != ErrOpen {
+		t.Fatal("tampered mode not detected:", err)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	blob, err := Seal(aesKey, []byte("message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modify := func(i int, b byte) []byte {
+		bad := append([]byte{}, blob...)
+		bad[i] = b
+		return bad
+	}
+
+	tests := []struct {
+		blob []byte
+		key  Key
+		err  error
+	}{
+		{nil, aesKey, ErrInvalidHeader},
+		{blob[:headerSize-1], aesKey, ErrInvalidHeader},
+		{blob[:headerSize+nonceSize+tagSize-1], aesKey, ErrInvalidHeader},
+		{modify(0, 'X'), aesKey, ErrInvalidHeader},
+		{modify(2, 2), aesKey, ErrUnsupportedVersion},
+		{modify(4, 0), aesKey, ErrInvalidHeader},
+		{modify(5, 8), aesKey, ErrInvalidHeader},
+		{blob, SM4Key(aesKey), ErrAlgorithmMismatch},
+	}
+	for i, test := range tests {
+		if _, err := Open(test.key, test.blob, nil); err != test.err {
+			t.Fatal("case", i, "got", err, "want", test.err)
+		}
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	if _, err := Seal(SM4Key(make([]byte, 15)), nil, nil); err == nil {
+		t.Fatal("short SM4 key accepted")
+	}
+	if _, err := Seal(AESKey(make([]byte, 20)), nil, nil); err == nil {
+		t.Fatal("invalid AES key accepted")
+	}
+	if _, err := SealWithMode(sm4Key, Mode(9), nil, nil); err == nil {
+		t.Fatal("unknown mode accepted")
+	}
+}
+
+func sealStream(t *testing.T, key Key, shift int, plaintext []byte) []byte {
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, key, shift)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/**
+	 * 以不规则的长度分多次写入
+	 */
+	for p, n := plaintext, 1; len(p) > 0; n = n*3 + 1 {
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openStream(key Key, stream []byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(stream), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	const chunk = 1 << minChunkShift
+	plaintext := make([]byte, 5*chunk+7)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+
+	for _, key := range []Key{sm4Key, aesKey} {
+		for _, n := range []int{0, 1, chunk - 1, chunk, chunk + 1, 3 * chunk, len(plaintext)} {
+			stream := sealStream(t, key, minChunkShift, plaintext[:n])
+			chunks := n/chunk + 1
+			if n%chunk == 0 && n > 0 {
+				chunks--
+			}
+			if len(stream) != streamHeaderSize+n+chunks*tagSize {
+				t.Fatal("unexpected stream length", n, len(stream))
+			}
+
+			out, err := openStream(key, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, plaintext[:n]) {
+				t.Fatal("plaintext mismatch, length", n)
+			}
+		}
+	}
+}
+
+func TestStreamDefault(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789"), 20000)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, sm4Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal("second Close failed:", err)
+	}
+	if _, err := w.Write([]byte("x")); err != ErrStreamClosed {
+		t.Fatal("write after Close should fail")
+	}
+
+	out, err := openStream(sm4Key, buf.Bytes())
+	if err != nil || !bytes.Equal(out, plaintext) {
+		t.Fatal("round trip failed:", err)
+	}
+
+	/**
+	 * 同一密钥的两个流使用不同的 salt
+	 */
+	a := sealStream(t, sm4Key, minChunkShift, plaintext[:100])
+	b := sealStream(t, sm4Key, minChunkShift, plaintext[:100])
+	if bytes.Equal(a[streamHeaderSize:], b[streamHeaderSize:]) {
+		t.Fatal("two streams share a key")
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	const chunk = 1 << minChunkShift
+	const sealed = chunk + tagSize
+	plaintext := make([]byte, 3*chunk+100)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	stream := sealStream(t, aesKey, minChunkShift, plaintext)
+
+	body := func(i int) []byte {
+		start := streamHeaderSize + i*sealed
+		end := start + sealed
+		if end > len(stream) {
+			end = len(stream)
+		}
+		return stream[start:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	header := stream[:streamHeaderSize]
+	set := func(i int, b byte) []byte {
+		bad := append([]byte{}, stream...)
+		bad[i] = b
+		return bad
+	}
+	flip := func(i int) []byte {
+		return set(i, stream[i]^1)
+	}
+
+	tests := []struct {
+		name   string
+		stream []byte
+		err    error
+	}{
+		{"truncated at chunk boundary", join(header, body(0), body(1)), ErrTruncated},
+		{"truncated header only", join(header), ErrTruncated},
+		{"truncated inside chunk", stream[:len(stream)-1], ErrOpen},
+		{"chunk dropped", join(header, body(0), body(2), body(3)), ErrOpen},
+		{"chunks swapped", join(header, body(1), body(0), body(2), body(3)), ErrOpen},
+		{"chunk appended", join(stream, body(1)), ErrOpen},
+		{"salt modified", flip(10), ErrOpen},
+		{"ciphertext modified", flip(streamHeaderSize + chunk + 5), ErrOpen},
+		{"tag modified", flip(len(stream) - 1), ErrOpen},
+		{"magic modified", flip(0), ErrInvalidHeader},
+		{"version modified", flip(2), ErrUnsupportedVersion},
+		{"algorithm modified", flip(3), ErrAlgorithmMismatch},
+		{"mode modified", flip(4), ErrInvalidHeader},
+		{"chunk size modified", flip(5), ErrOpen},
+		{"chunk size too large", set(5, maxChunkShift+1), ErrInvalidHeader},
+		{"chunk size too small", set(5, minChunkShift-1), ErrInvalidHeader},
+		{"short header", stream[:streamHeaderSize-1], ErrInvalidHeader},
+	}
+	for _, test := range tests {
+		out, err := openStream(aesKey, test.stream)
+		if err != test.err {
+			t.Fatal(test.name, "got", err, "want", test.err)
+		}
+		if len(out)%chunk != 0 || !bytes.Equal(out, plaintext[:len(out)]) {
+			t.Fatal(test.name, "returned unauthenticated data")
+		}
+	}
+
+	/**
+	 * 第二块被篡改时，已认证的第一块仍然可读，之后返回错误
+	 */
+	r, _ := NewReader(bytes.NewReader(flip(streamHeaderSize+sealed+5)), aesKey)
+	buf := make([]byte, 3*chunk)
+	n, err := io.ReadFull(r, buf)
+	if n != chunk || err != ErrOpen {
+		t.Fatal("got", n, err)
+	}
+	if _, err := r.Read(buf); err != ErrOpen {
+		t.Fatal("error should be sticky")
+	}
+}
+
+func TestStreamInvalidKey(t *testing.T) {
+	if _, err := NewWriter(ioutil.Discard, SM4Key(make([]byte, 15))); err == nil {
+		t.Fatal("short SM4 key accepted")
+	}
+	stream := sealStream(t, sm4Key, minChunkShift, []byte("message"))
+	if _, err := openStream(SM4Key(make([]byte, 16)), stream); err != ErrOpen {
+		t.Fatal("wrong key accepted:", err)
+	}
+}
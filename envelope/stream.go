@@ -0,0 +1,275 @@
+package envelope
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+/**
+ * 分块流式加密格式 (version 1)，基于 STREAM 构造
+ *
+ *   +-------+---------+-----------+------+-------------+-------+---------+-----+---------+
+ *   | magic | version | algorithm | mode | chunk shift | salt  | chunk 0 | ... | chunk n |
+ *   | "GS"  |  1 byte |   1 byte  | 1 B  |    1 byte   | 16 B  |         |     |         |
+ *   +-------+---------+-----------+------+-------------+-------+---------+-----+---------+
+ *
+ * - 每个流使用随机 salt 派生独立的密钥: HKDF-SM3(key, salt, header[:6])
+ * - 明文按 2^shift 字节分块，每块加密为 密文 || 16字节标签，最后一块可以更短(包括空块)
+ * - 第 i 块的随机数为 0^7 || i (4字节大端) || last，last 仅在最后一块为 1
+ * - 整个头部作为每块的附加数据
+ *
+ * 计数器防止分块被重排，last 标志防止在块边界处截断或追加
+ * 目前只支持 GCM 模式
+ */
+const (
+	streamHeaderSize = 6 + saltSize
+	saltSize         = 16
+
+	//DefaultChunkShift - 默认分块大小 64KiB
+	DefaultChunkShift = 16
+
+	minChunkShift = 10
+	maxChunkShift = 24
+)
+
+var streamMagic = [2]byte{'G', 'S'}
+
+var (
+	ErrTruncated    = errors.New("envelope: stream truncated")
+	ErrStreamClosed = errors.New("envelope: write to closed stream")
+	errTooManyChunk = errors.New("envelope: stream exceeds 2^32 chunks")
+)
+
+/**
+ * 第 i 块的随机数
+ */
+func chunkNonce(nonce *[nonceSize]byte, counter uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[7:], counter)
+	nonce[11] = 0
+	if last {
+		nonce[11] = 1
+	}
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	size    int
+	counter uint32
+	err     error
+}
+
+//NewWriter - 写入流头部，返回的 Writer 分块加密写入 w，必须调用 Close 写入最后一块
+func NewWriter(w io.Writer, key Key) (io.WriteCloser, error) {
+	return newWriter(w, key, DefaultChunkShift)
+}
+
+func newWriter(w io.Writer, key Key, shift int) (io.WriteCloser, error) {
+	header := make([]byte, streamHeaderSize)
+	copy(header, streamMagic[:])
+	header[2] = Version1
+	header[3] = byte(key.algorithm())
+	header[4] = byte(ModeGCM)
+	header[5] = byte(shift)
+	if _, err := io.ReadFull(rand.Reader, header[6:]); err != nil {
+		return nil, err
+	}
+
+	aead, err := streamAEAD(key, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	size := 1 << uint(shift)
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, size+aead.Overhead()),
+		size:   size,
+	}, nil
+}
+
+func streamAEAD(key Key, header []byte) (cipher.AEAD, error) {
+	k, err := key.derive(header[6:], header[:6])
+	if err != nil {
+		return nil, err
+	}
+	return k.newAEAD(ModeGCM)
+}
+
+/**
+ * 缓冲区满且还有后续数据时才加密当前块，保证最后一块在 Close 时写出
+ */
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(sw.buf) == sw.size {
+			if err := sw.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := sw.size - len(sw.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		sw.buf = append(sw.buf, p[:n]...)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (sw *streamWriter) flush(last bool) error {
+	if !last && sw.counter == 1<<32-1 {
+		sw.err = errTooManyChunk
+		return sw.err
+	}
+
+	var nonce [nonceSize]byte
+	chunkNonce(&nonce, sw.counter, last)
+	out := sw.aead.Seal(sw.buf[:0], nonce[:], sw.buf, sw.header)
+	if _, err := sw.w.Write(out); err != nil {
+		sw.err = err
+		return err
+	}
+	sw.counter++
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+//Close - 写入最后一块，不关闭底层的 Writer
+func (sw *streamWriter) Close() error {
+	if sw.err == ErrStreamClosed {
+		return nil
+	}
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.flush(true); err != nil {
+		return err
+	}
+	sw.err = ErrStreamClosed
+	return nil
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	out     []byte
+	plain   []byte
+	counter uint32
+	done    bool
+	err     error
+}
+
+//NewReader - 读取并校验流头部，返回的 Reader 逐块解密
+// 只返回通过认证的明文；流被截断、重排或篡改时返回 ErrTruncated 或 ErrOpen
+func NewReader(r io.Reader, key Key) (io.Reader, error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if header[0] != streamMagic[0] || header[1] != streamMagic[1] {
+		return nil, ErrInvalidHeader
+	}
+	if header[2] != Version1 {
+		return nil, ErrUnsupportedVersion
+	}
+	if Algorithm(header[3]) != key.algorithm() {
+		return nil, ErrAlgorithmMismatch
+	}
+	if Mode(header[4]) != ModeGCM || header[5] < minChunkShift || header[5] > maxChunkShift {
+		return nil, ErrInvalidHeader
+	}
+
+	aead, err := streamAEAD(key, header)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 1<<header[5]+aead.Overhead()),
+		out:    make([]byte, 1<<header[5]),
+	}, nil
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.plain) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			return 0, io.EOF
+		}
+		sr.err = sr.readChunk()
+	}
+
+	n := copy(p, sr.plain)
+	sr.plain = sr.plain[n:]
+	return n, nil
+}
+
+/**
+ * 读取一个完整的密文块；块不满或其后没有数据时为最后一块
+ * 密文与明文使用不同的缓冲区，认证失败时可以用另一个标志重试
+ */
+func (sr *streamReader) readChunk() error {
+	n, err := io.ReadFull(sr.r, sr.buf)
+	last := false
+	switch err {
+	case nil:
+		if _, err := sr.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF:
+		return ErrTruncated
+	case io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	if !last && sr.counter == 1<<32-1 {
+		return ErrOpen
+	}
+
+	var nonce [nonceSize]byte
+	chunkNonce(&nonce, sr.counter, last)
+	plain, err := sr.aead.Open(sr.out[:0], nonce[:], sr.buf[:n], sr.header)
+	if err != nil {
+		/**
+		 * 作为中间块能通过认证说明其后的块被截掉了
+		 */
+		if last {
+			chunkNonce(&nonce, sr.counter, false)
+			if _, err := sr.aead.Open(sr.out[:0], nonce[:], sr.buf[:n], sr.header); err == nil {
+				return ErrTruncated
+			}
+		}
+		return ErrOpen
+	}
+	sr.plain = plain
+	sr.counter++
+	sr.done = last
+	return nil
+}
@@ -0,0 +1,31 @@
+package alias
+
+import "unsafe"
+
+//AnyOverlap - x 与 y 是否共享内存
+func AnyOverlap(x, y []byte) bool {
+	return len(x) > 0 && len(y) > 0 &&
+		uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
+
+//InexactOverlap - x 与 y 是否共享内存但起始位置不同
+// 原地操作 (dst 与 src 起始相同) 是允许的，错位的重叠则不允许
+func InexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return AnyOverlap(x, y)
+}
+
+//SliceForAppend - 将 in 扩展 n 字节，返回整个切片及扩展出的部分
+func SliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
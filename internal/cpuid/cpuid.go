@@ -0,0 +1,9 @@
+package cpuid
+
+/**
+ * 运行时检测 CPU 特性，供汇编实现选择使用
+ */
+var (
+	HasAES   bool // AES-NI
+	HasSSSE3 bool // PSHUFB
+)
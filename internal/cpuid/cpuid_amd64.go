@@ -0,0 +1,14 @@
+package cpuid
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func init() {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return
+	}
+	_, _, ecx, _ := cpuid(1, 0)
+	HasSSSE3 = ecx&(1<<9) != 0
+	HasAES = ecx&(1<<25) != 0
+}
@@ -0,0 +1,92 @@
+package gf256
+
+import (
+	"math/bits"
+)
+
+/**
+ * 比特切片 (bitsliced) 的 GF(2^8) 运算，不查表、无分支，耗时与数据无关
+ * 最多同时处理32个字节: s[i] 的第 j 位为第 j 个字节的第 i 位 (x^i 的系数)
+ * poly 为约简多项式去掉 x^8 后的低8位，例如 AES 为 0x1B，SM4 为 0xF5
+ */
+type Slice [8]uint32
+
+//Load - 将最多32个字节转换为比特切片
+func Load(src []byte) (s Slice) {
+	for j, b := range src {
+		for i := range s {
+			s[i] |= uint32(b>>uint(i)&1) << uint(j)
+		}
+	}
+	return
+}
+
+//Store - 将比特切片转换回字节，写入 len(dst) 个字节
+func (s *Slice) Store(dst []byte) {
+	for j := range dst {
+		var b byte
+		for i := range s {
+			b |= byte(s[i]>>uint(j)&1) << uint(i)
+		}
+		dst[j] = b
+	}
+}
+
+//Mul - x * y mod (x^8 + poly)
+func Mul(x, y *Slice, poly byte) (z Slice) {
+	var p [15]uint32
+	y0, y1, y2, y3, y4, y5, y6, y7 := y[0], y[1], y[2], y[3], y[4], y[5], y[6], y[7]
+	for i := 0; i < 8; i++ {
+		xi, q := x[i], p[i:i+8]
+		q[0] ^= xi & y0
+		q[1] ^= xi & y1
+		q[2] ^= xi & y2
+		q[3] ^= xi & y3
+		q[4] ^= xi & y4
+		q[5] ^= xi & y5
+		q[6] ^= xi & y6
+		q[7] ^= xi & y7
+	}
+	return reduce(&p, poly)
+}
+
+//Square - x^2 mod (x^8 + poly)，平方是线性运算: x^i 映射为 x^2i
+func Square(x *Slice, poly byte) Slice {
+	var p [15]uint32
+	for i := 0; i < 8; i++ {
+		p[2*i] = x[i]
+	}
+	return reduce(&p, poly)
+}
+
+/**
+ * x^8 = poly，从高次项开始约简，循环只依赖于公开的 poly
+ */
+func reduce(p *[15]uint32, poly byte) (z Slice) {
+	for k := 14; k >= 8; k-- {
+		pk := p[k]
+		for m := poly; m != 0; m &= m - 1 {
+			p[k-8+bits.TrailingZeros8(m)] ^= pk
+		}
+	}
+	copy(z[:], p[:8])
+	return
+}
+
+/**
+ * 求逆 x^-1 = x^254，0 的逆定义为 0
+ * x^2, x^3, x^6, x^12, x^15, x^240, x^252, x^254
+ */
+func Inverse(x *Slice, poly byte) Slice {
+	x2 := Square(x, poly)
+	x3 := Mul(&x2, x, poly)
+	x6 := Square(&x3, poly)
+	x12 := Square(&x6, poly)
+	x15 := Mul(&x12, &x3, poly)
+	y := x15
+	for i := 0; i < 4; i++ {
+		y = Square(&y, poly)
+	}
+	y = Mul(&y, &x12, poly)
+	return Mul(&y, &x2, poly)
+}
@@ -0,0 +1,94 @@
+package kdf
+
+import (
+	"crypto/hmac"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/anhk/crypto/sm3"
+)
+
+/**
+ * HKDF (RFC 5869)
+ * - PRK = HMAC-Hash(salt, IKM)
+ * - T(i) = HMAC-Hash(PRK, T(i-1) || info || i)，输出 T(1) || T(2) || ...，最多255块
+ * h 为 nil 时使用 SM3
+ */
+
+func hashOrDefault(h func() hash.Hash) func() hash.Hash {
+	if h == nil {
+		return sm3.New
+	}
+	return h
+}
+
+//HKDFExtract - 由输入密钥材料和盐计算伪随机密钥 PRK，salt 为空时使用全0
+func HKDFExtract(h func() hash.Hash, secret, salt []byte) []byte {
+	h = hashOrDefault(h)
+	if salt == nil {
+		salt = make([]byte, h().Size())
+	}
+	mac := hmac.New(h, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+type hkdfReader struct {
+	expander hash.Hash
+	size     int
+	info     []byte
+	counter  byte
+	prev     []byte
+	buf      []byte
+}
+
+//HKDFExpand - 以流的方式读取由 PRK 和 info 扩展的密钥，最多 255 个摘要长度
+func HKDFExpand(h func() hash.Hash, prk, info []byte) io.Reader {
+	expander := hmac.New(hashOrDefault(h), prk)
+	return &hkdfReader{
+		expander: expander,
+		size:     expander.Size(),
+		info:     append([]byte{}, info...),
+		counter:  1,
+	}
+}
+
+func (r *hkdfReader) Read(p []byte) (int, error) {
+	need := len(p)
+	remains := len(r.buf) + int(255-r.counter+1)*r.size
+	if remains < need {
+		return 0, errors.New("kdf: entropy limit reached")
+	}
+
+	n := copy(p, r.buf)
+	p = p[n:]
+
+	for len(p) > 0 {
+		r.expander.Reset()
+		r.expander.Write(r.prev)
+		r.expander.Write(r.info)
+		r.expander.Write([]byte{r.counter})
+		r.prev = r.expander.Sum(r.prev[:0])
+		r.counter++
+
+		r.buf = r.prev
+		n = copy(p, r.buf)
+		p = p[n:]
+	}
+	r.buf = r.buf[n:]
+	return need, nil
+}
+
+//HKDF - Extract 后 Expand，返回 keyLen 字节的密钥
+func HKDF(h func() hash.Hash, secret, salt, info []byte, keyLen int) ([]byte, error) {
+	if keyLen < 0 {
+		return nil, errors.New("kdf: invalid derived key length")
+	}
+	prk := HKDFExtract(h, secret, salt)
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(HKDFExpand(h, prk, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
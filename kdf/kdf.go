@@ -0,0 +1,69 @@
+package kdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/anhk/crypto/sm3"
+)
+
+/**
+ * 密钥派生函数 (GB/T 32918.4 5.4.3)
+ * K = H(Z || ct1) || H(Z || ct2) || ...，ct 为从1开始的32位大端计数器
+ * klen 不能超过 (2^32 - 1) 个摘要长度
+ */
+const maxBlocks = math.MaxUint32
+
+var errExhausted = errors.New("kdf: key stream exhausted")
+
+//KDF - 由共享秘密 z 派生 klen 字节的密钥
+func KDF(z []byte, klen int) []byte {
+	if klen < 0 || uint64(klen) > maxBlocks*sm3.DigestLength {
+		panic("kdf: invalid key length")
+	}
+	out := make([]byte, klen)
+	io.ReadFull(NewReader(z), out)
+	return out
+}
+
+type reader struct {
+	h   hash.Hash
+	z   []byte
+	ct  uint32
+	buf []byte
+	off int
+}
+
+//NewReader - 以流的方式读取 KDF 的输出，适用于派生较长的密钥，
+// 读完 (2^32 - 1) 个摘要后返回错误
+func NewReader(z []byte) io.Reader {
+	return &reader{
+		h: sm3.New(),
+		z: append([]byte{}, z...),
+	}
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if r.off == len(r.buf) {
+			if r.ct == maxBlocks {
+				return n, errExhausted
+			}
+			r.ct++
+			var ct [4]byte
+			binary.BigEndian.PutUint32(ct[:], r.ct)
+			r.h.Reset()
+			r.h.Write(r.z)
+			r.h.Write(ct[:])
+			r.buf = r.h.Sum(r.buf[:0])
+			r.off = 0
+		}
+		m := copy(p[n:], r.buf[r.off:])
+		r.off += m
+		n += m
+	}
+	return n, nil
+}
@@ -0,0 +1,226 @@
+package kdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"testing"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/sm3"
+	"github.com/anhk/crypto/sm4"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * GB/T 32918.4 附录A 加密示例: Z = x2 || y2, klen = 152 比特
+ */
+var z = mustHex("335e18d751e51f040e27d468138b7ab1dc86ad7f981d7d416222fd6ab3ed230d" +
+	"ab743ebcfb22d64f7b6ab791f70658f25b48fa93e54064fdbfbed3f0bd847ac9")
+
+func TestKDF(t *testing.T) {
+	if k := hex.EncodeToString(KDF(z, 19)); k != "44e60fdbf0bae81437665374bef26749046c9e" {
+		t.Fatal("invalid kdf", k)
+	}
+
+	/**
+	 * 多个摘要长度，与 OpenSSL X963KDF (digest:SM3) 的输出一致
+	 */
+	expected := "44e60fdbf0bae81437665374bef26749046c9e038663294a24f3eccc533e579a" +
+		"75abe2630d06376d2a947c0755c7c053cbb7d66046bc7b1e057698692ebd905e" +
+		"8ff15cd2879a1614f80a0e487a04a12bfe517d00e0145e46136036f0a83c853f" +
+		"637e691b"
+	if k := hex.EncodeToString(KDF(z, 100)); k != expected {
+		t.Fatal("invalid kdf", k)
+	}
+
+	if len(KDF(z, 0)) != 0 {
+		t.Fatal("invalid empty kdf")
+	}
+}
+
+func TestReader(t *testing.T) {
+	expected := KDF(z, 1000)
+
+	/**
+	 * 以不同的长度分段读取
+	 */
+	for _, step := range []int{1, 7, 31, 32, 33, 100} {
+		r := NewReader(z)
+		var out []byte
+		buf := make([]byte, step)
+		for len(out) < len(expected) {
+			n, err := r.Read(buf)
+			if err != nil || n != step {
+				t.Fatal(n, err)
+			}
+			out = append(out, buf...)
+		}
+		if !bytes.Equal(out[:len(expected)], expected) {
+			t.Fatal("step", step, "reader mismatch")
+		}
+	}
+
+	/**
+	 * 读取器保存 z 的副本
+	 */
+	zz := append([]byte{}, z...)
+	r := NewReader(zz)
+	zz[0] ^= 1
+	out := make([]byte, 64)
+	io.ReadFull(r, out)
+	if !bytes.Equal(out, expected[:64]) {
+		t.Fatal("reader depends on caller's buffer")
+	}
+}
+
+func TestReaderExhausted(t *testing.T) {
+	r := NewReader(z).(*reader)
+	r.ct = maxBlocks - 1
+
+	out := make([]byte, 40)
+	n, err := r.Read(out)
+	if n != 32 || err != errExhausted {
+		t.Fatal(n, err)
+	}
+	if n, err := r.Read(out); n != 0 || err != errExhausted {
+		t.Fatal(n, err)
+	}
+}
+
+/**
+ * RFC 5869 测试用例1 的输入；SHA-256 的期望值取自 RFC 5869，
+ * SM3 的期望值由 OpenSSL 3.0.17 计算 (IKM 为 22 字节 0b，下同):
+ *   openssl kdf -keylen 32 -kdfopt digest:SM3 -kdfopt mode:EXTRACT_ONLY -kdfopt hexkey:0b0b...0b \
+ *     -kdfopt hexsalt:000102030405060708090a0b0c HKDF
+ *   openssl kdf -keylen 42 -kdfopt digest:SM3 -kdfopt hexkey:0b0b...0b \
+ *     -kdfopt hexsalt:000102030405060708090a0b0c -kdfopt hexinfo:f0f1f2f3f4f5f6f7f8f9 HKDF
+ *   openssl kdf -keylen 42 -kdfopt digest:SM3 -kdfopt hexkey:0b0b...0b HKDF
+ */
+func TestHKDF(t *testing.T) {
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+	salt := mustHex("000102030405060708090a0b0c")
+	info := mustHex("f0f1f2f3f4f5f6f7f8f9")
+
+	prk := HKDFExtract(sha256.New, ikm, salt)
+	if hex.EncodeToString(prk) != "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5" {
+		t.Fatal("invalid sha256 prk")
+	}
+	okm, err := HKDF(sha256.New, ikm, salt, info, 42)
+	if err != nil || hex.EncodeToString(okm) != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865" {
+		t.Fatal("invalid sha256 okm", err)
+	}
+
+	prk = HKDFExtract(nil, ikm, salt)
+	if hex.EncodeToString(prk) != "e0d6f7b0bd056327b7659f1f39ad850561fbcf4fb10fb58e88eafa55cf7cd01e" {
+		t.Fatal("invalid sm3 prk")
+	}
+	okm, err = HKDF(sm3.New, ikm, salt, info, 42)
+	if err != nil || hex.EncodeToString(okm) != "c69fe91b7aaee2dd5718d72dcaee0cce93f1b8e41f792da51261b6a517e68b36ed2c595572b01dfa359b" {
+		t.Fatal("invalid sm3 okm", err)
+	}
+	okm, err = HKDF(nil, ikm, nil, nil, 42)
+	if err != nil || hex.EncodeToString(okm) != "c8c91a38ae2fb3b023a7c38ce9f0748f28230d59b6b950ba3ba949bf0d713a5774815778801741cb2034" {
+		t.Fatal("invalid sm3 okm without salt and info", err)
+	}
+}
+
+func TestHKDFExpandLimit(t *testing.T) {
+	prk := HKDFExtract(nil, []byte("secret"), nil)
+
+	/**
+	 * 分段读取与一次读取一致
+	 */
+	all := make([]byte, 255*32)
+	if _, err := io.ReadFull(HKDFExpand(nil, prk, []byte("info")), all); err != nil {
+		t.Fatal(err)
+	}
+	r := HKDFExpand(nil, prk, []byte("info"))
+	for i := 0; i < len(all); i += 17 {
+		n := 17
+		if i+n > len(all) {
+			n = len(all) - i
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, all[i:i+n]) {
+			t.Fatal("chunked expand mismatch at", i, err)
+		}
+	}
+	if _, err := r.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read beyond 255 blocks")
+	}
+
+	if _, err := HKDF(nil, []byte("secret"), nil, nil, 255*32+1); err == nil {
+		t.Fatal("derived key longer than 255 blocks")
+	}
+}
+
+/**
+ * SHA-1 的期望值取自 RFC 6070，
+ * SM3 的期望值由 OpenSSL 3.0.17 计算:
+ *   openssl kdf -keylen <keyLen> -kdfopt digest:SM3 -kdfopt pass:<password> -kdfopt salt:<salt> \
+ *     -kdfopt iter:<iter> PBKDF2
+ */
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		h              func() hash.Hash
+		password, salt string
+		iter, keyLen   int
+		key            string
+	}{
+		{sha1.New, "password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{sha1.New, "password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{sha1.New, "password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{nil, "password", "salt", 1, 32, "4612f922a1fdcefaf4312fc6f8f3322b489cbf24f2ea361b44c2bd8fa2c6dcb0"},
+		{nil, "password", "salt", 2, 32, "fee723a2bc966e11dffb66133f4e8df577383c78ade30e3298edbd3e54ed85b7"},
+		{sm3.New, "password", "salt", 4096, 32, "b6e8f2074c87432b78f62e5ced980fdff89e86af2f693dab1638e2b3683045dd"},
+		{sm3.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+			"3b6282ac8519f059e465abff0ea37b0dbfe6c672a76e6b805312d53900db630732ccc1a88fa5512a"},
+	}
+	for i, test := range tests {
+		key, err := PBKDF2(test.h, []byte(test.password), []byte(test.salt), test.iter, test.keyLen)
+		if err != nil || hex.EncodeToString(key) != test.key {
+			t.Fatal("case", i, "got", hex.EncodeToString(key), err)
+		}
+	}
+
+	if _, err := PBKDF2(nil, []byte("password"), []byte("salt"), 1, -1); err == nil {
+		t.Fatal("negative key length should fail")
+	}
+	if _, err := PBKDF2(nil, []byte("password"), []byte("salt"), 0, 32); err == nil {
+		t.Fatal("zero iteration count should fail")
+	}
+	if _, err := HKDF(nil, []byte("secret"), nil, nil, -1); err == nil {
+		t.Fatal("negative key length should fail")
+	}
+}
+
+func TestDerivedCipherKeys(t *testing.T) {
+	key, err := PBKDF2(nil, []byte("password"), []byte("salt"), 1000, sm4.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm4.NewCipher(key); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{16, 24, 32} {
+		key, err := HKDF(nil, []byte("secret"), []byte("salt"), []byte("aes key"), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
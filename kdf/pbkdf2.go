@@ -0,0 +1,56 @@
+package kdf
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+/**
+ * PBKDF2 (RFC 8018)
+ * T_i = U_1 ^ U_2 ^ ... ^ U_c
+ * U_1 = PRF(P, S || INT(i))，U_j = PRF(P, U_{j-1})
+ * PRF 为 HMAC-Hash，h 为 nil 时使用 SM3
+ */
+
+//PBKDF2 - 由口令派生 keyLen 字节的密钥，iter 为迭代次数
+func PBKDF2(h func() hash.Hash, password, salt []byte, iter, keyLen int) ([]byte, error) {
+	if iter < 1 {
+		return nil, errors.New("kdf: invalid iteration count")
+	}
+	prf := hmac.New(hashOrDefault(h), password)
+	hashLen := prf.Size()
+
+	/**
+	 * dkLen 不超过 (2^32 - 1) * hLen
+	 */
+	if keyLen < 0 || uint64(keyLen) > (1<<32-1)*uint64(hashLen) {
+		return nil, errors.New("kdf: invalid derived key length")
+	}
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen], nil
+}
@@ -0,0 +1,190 @@
+package keywrap
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+/**
+ * 密钥包装 (Key Wrap)
+ * - KW:  RFC 3394 / NIST SP 800-38F，被包装的密钥为 8 字节的整数倍且不少于 16 字节
+ * - KWP: RFC 5649 / NIST SP 800-38F，被包装的密钥为任意 1 ~ 2^32-1 字节
+ * 适用于任意128比特分组密码，包装结果比原密钥长 8 ~ 15 字节
+ */
+const (
+	blockSize     = 16
+	semiblockSize = 8
+)
+
+var (
+	defaultIV = [semiblockSize]byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+	paddedIV  = [4]byte{0xA6, 0x59, 0x59, 0xA6}
+)
+
+var (
+	errBlockSize  = errors.New("keywrap: cipher does not have a block size of 16")
+	errKeyLength  = errors.New("keywrap: invalid key length")
+	errWrapLength = errors.New("keywrap: invalid wrapped key length")
+)
+
+//IntegrityError - 解包时完整性校验失败，值为工作模式 ("KW" 或 "KWP")
+// 密钥包装密钥不匹配或密文被篡改时返回
+type IntegrityError string
+
+func (e IntegrityError) Error() string {
+	return "keywrap: " + string(e) + " integrity check failed"
+}
+
+//Wrap - RFC 3394 KW 包装，len(key) 为 8 的整数倍且不少于 16 字节
+func Wrap(b cipher.Block, key []byte) ([]byte, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errBlockSize
+	}
+	if len(key) < 2*semiblockSize || len(key)%semiblockSize != 0 {
+		return nil, errKeyLength
+	}
+
+	out := make([]byte, semiblockSize+len(key))
+	copy(out, defaultIV[:])
+	copy(out[semiblockSize:], key)
+	wrap(b, out)
+	return out, nil
+}
+
+//Unwrap - RFC 3394 KW 解包，校验失败时返回 IntegrityError
+func Unwrap(b cipher.Block, wrapped []byte) ([]byte, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errBlockSize
+	}
+	if len(wrapped) < 3*semiblockSize || len(wrapped)%semiblockSize != 0 {
+		return nil, errWrapLength
+	}
+
+	out := append([]byte{}, wrapped...)
+	unwrap(b, out)
+	if subtle.ConstantTimeCompare(out[:semiblockSize], defaultIV[:]) != 1 {
+		return nil, IntegrityError("KW")
+	}
+	return out[semiblockSize:], nil
+}
+
+//WrapWithPadding - RFC 5649 KWP 包装，len(key) 为 1 ~ 2^32-1 字节
+func WrapWithPadding(b cipher.Block, key []byte) ([]byte, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errBlockSize
+	}
+	if len(key) == 0 || uint64(len(key)) > math.MaxUint32 {
+		return nil, errKeyLength
+	}
+
+	/**
+	 * AIV = A65959A6 || MLI(32比特大端长度)，密钥补0至 8 字节的整数倍
+	 * 只有一个半块时直接加密 AIV || P
+	 */
+	padded := (len(key) + semiblockSize - 1) / semiblockSize * semiblockSize
+	out := make([]byte, semiblockSize+padded)
+	copy(out, paddedIV[:])
+	binary.BigEndian.PutUint32(out[4:], uint32(len(key)))
+	copy(out[semiblockSize:], key)
+
+	if padded == semiblockSize {
+		b.Encrypt(out, out)
+	} else {
+		wrap(b, out)
+	}
+	return out, nil
+}
+
+//UnwrapWithPadding - RFC 5649 KWP 解包，校验失败时返回 IntegrityError
+func UnwrapWithPadding(b cipher.Block, wrapped []byte) ([]byte, error) {
+	if b.BlockSize() != blockSize {
+		return nil, errBlockSize
+	}
+	if len(wrapped) < 2*semiblockSize || len(wrapped)%semiblockSize != 0 {
+		return nil, errWrapLength
+	}
+
+	out := append([]byte{}, wrapped...)
+	if len(out) == blockSize {
+		b.Decrypt(out, out)
+	} else {
+		unwrap(b, out)
+	}
+
+	/**
+	 * 检查 AIV 前缀、长度 MLI 满足 8*(n-1) < MLI <= 8*n，以及填充字节均为0
+	 */
+	padded := len(out) - semiblockSize
+	mli := int(binary.BigEndian.Uint32(out[4:semiblockSize]))
+	good := subtle.ConstantTimeCompare(out[:4], paddedIV[:])
+	good &= subtle.ConstantTimeLessOrEq(padded-semiblockSize+1, mli)
+	good &= subtle.ConstantTimeLessOrEq(mli, padded)
+
+	var nonZero byte
+	for k := padded - semiblockSize + 1; k < padded; k++ {
+		inPad := subtle.ConstantTimeLessOrEq(mli, k)
+		nonZero |= byte(inPad) * out[semiblockSize+k]
+	}
+	good &= subtle.ConstantTimeByteEq(nonZero, 0)
+
+	if good != 1 {
+		return nil, IntegrityError("KWP")
+	}
+	return out[semiblockSize : semiblockSize+mli], nil
+}
+
+/**
+ * 包装函数 W，data = A || R[1] || ... || R[n]，原地计算
+ * for j = 0..5, i = 1..n:
+ *   B = E(K, A || R[i])
+ *   A = MSB64(B) ^ t，t = n*j + i
+ *   R[i] = LSB64(B)
+ */
+func wrap(b cipher.Block, data []byte) {
+	n := len(data)/semiblockSize - 1
+	var block [blockSize]byte
+	copy(block[:semiblockSize], data)
+
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			r := data[i*semiblockSize : (i+1)*semiblockSize]
+			copy(block[semiblockSize:], r)
+			b.Encrypt(block[:], block[:])
+			xorCounter(block[:semiblockSize], uint64(n*j+i))
+			copy(r, block[semiblockSize:])
+		}
+	}
+	copy(data, block[:semiblockSize])
+}
+
+/**
+ * 解包函数 W^-1，按相反顺序计算
+ *   B = D(K, (A ^ t) || R[i])
+ */
+func unwrap(b cipher.Block, data []byte) {
+	n := len(data)/semiblockSize - 1
+	var block [blockSize]byte
+	copy(block[:semiblockSize], data)
+
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := data[i*semiblockSize : (i+1)*semiblockSize]
+			xorCounter(block[:semiblockSize], uint64(n*j+i))
+			copy(block[semiblockSize:], r)
+			b.Decrypt(block[:], block[:])
+			copy(r, block[semiblockSize:])
+		}
+	}
+	copy(data, block[:semiblockSize])
+}
+
+func xorCounter(a []byte, t uint64) {
+	var tb [semiblockSize]byte
+	binary.BigEndian.PutUint64(tb[:], t)
+	for i := range tb {
+		a[i] ^= tb[i]
+	}
+}
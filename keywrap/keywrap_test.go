@@ -0,0 +1,225 @@
+package keywrap
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * RFC 3394 第4节 4.1 ~ 4.6
+ */
+var kwTests = []struct {
+	kek, key, wrapped string
+}{
+	{
+		"000102030405060708090A0B0C0D0E0F", "00112233445566778899AABBCCDDEEFF",
+		"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5",
+	},
+	{
+		"000102030405060708090A0B0C0D0E0F1011121314151617", "00112233445566778899AABBCCDDEEFF",
+		"96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D",
+	},
+	{
+		"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F", "00112233445566778899AABBCCDDEEFF",
+		"64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7",
+	},
+	{
+		"000102030405060708090A0B0C0D0E0F1011121314151617", "00112233445566778899AABBCCDDEEFF0001020304050607",
+		"031D33264E15D33268F24EC260743EDCE1C6C7DDEE725A936BA814915C6762D2",
+	},
+	{
+		"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F", "00112233445566778899AABBCCDDEEFF0001020304050607",
+		"A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1",
+	},
+	{
+		"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F", "00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+		"28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21",
+	},
+}
+
+/**
+ * 前两组为 RFC 5649 第6节，其余与 OpenSSL id-aes192-wrap-pad 交叉验证
+ */
+var kwpTests = []struct {
+	kek, key, wrapped string
+}{
+	{
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "c37b7e6492584340bed12207808941155068f738",
+		"138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+	},
+	{
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "466f7250617369",
+		"afbeb0f07dfbf5419200f2ccb50bb24f",
+	},
+	{
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "01",
+		"ba613be6ce54c12de2e7540ae3bfede1",
+	},
+	{
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "0102030405060708",
+		"2231ff149c47a7030eccdea20930e216",
+	},
+	{
+		"5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8", "c37b7e6492584340bed1220780894115",
+		"d618f65d2e5c1d02b614e2fa03f0af4e94739d86a8ac4ded",
+	},
+}
+
+func TestWrap(t *testing.T) {
+	for i, test := range kwTests {
+		b, _ := aes.NewCipher(mustHex(test.kek))
+		wrapped, err := Wrap(b, mustHex(test.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, mustHex(test.wrapped)) {
+			t.Fatalf("#%d: invalid wrap %x", i, wrapped)
+		}
+
+		key, err := Unwrap(b, wrapped)
+		if err != nil || !bytes.Equal(key, mustHex(test.key)) {
+			t.Fatalf("#%d: invalid unwrap: %v", i, err)
+		}
+	}
+}
+
+func TestWrapWithPadding(t *testing.T) {
+	for i, test := range kwpTests {
+		b, _ := aes.NewCipher(mustHex(test.kek))
+		wrapped, err := WrapWithPadding(b, mustHex(test.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, mustHex(test.wrapped)) {
+			t.Fatalf("#%d: invalid wrap %x", i, wrapped)
+		}
+
+		key, err := UnwrapWithPadding(b, wrapped)
+		if err != nil || !bytes.Equal(key, mustHex(test.key)) {
+			t.Fatalf("#%d: invalid unwrap: %v", i, err)
+		}
+	}
+}
+
+func TestPaddingLengths(t *testing.T) {
+	b, _ := aes.NewCipher(make([]byte, 16))
+	key := make([]byte, 70)
+	for i := range key {
+		key[i] = byte(i + 1)
+	}
+	for n := 1; n <= len(key); n++ {
+		wrapped, err := WrapWithPadding(b, key[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(wrapped) != 8+(n+7)/8*8 {
+			t.Fatal("unexpected wrapped length", n, len(wrapped))
+		}
+		out, err := UnwrapWithPadding(b, wrapped)
+		if err != nil || !bytes.Equal(out, key[:n]) {
+			t.Fatal("round trip failed, length", n, err)
+		}
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	b, _ := aes.NewCipher(mustHex(kwTests[0].kek))
+	other, _ := aes.NewCipher(mustHex("0f0e0d0c0b0a09080706050403020100"))
+	wrapped := mustHex(kwTests[0].wrapped)
+	for i := range wrapped {
+		bad := append([]byte{}, wrapped...)
+		bad[i] ^= 0x80
+		if _, err := Unwrap(b, bad); err != IntegrityError("KW") {
+			t.Fatal("modified byte", i, "got", err)
+		}
+	}
+	if _, err := Unwrap(other, wrapped); err != IntegrityError("KW") {
+		t.Fatal("wrong KEK got", err)
+	}
+
+	/**
+	 * KW 与 KWP 的结果不能互相解包
+	 */
+	if _, err := UnwrapWithPadding(b, wrapped); err != IntegrityError("KWP") {
+		t.Fatal("KW unwrapped as KWP got", err)
+	}
+
+	b, _ = aes.NewCipher(mustHex(kwpTests[0].kek))
+	for _, test := range kwpTests {
+		wrapped := mustHex(test.wrapped)
+		for i := range wrapped {
+			bad := append([]byte{}, wrapped...)
+			bad[i] ^= 1
+			if _, err := UnwrapWithPadding(b, bad); err != IntegrityError("KWP") {
+				t.Fatal("modified byte", i, "got", err)
+			}
+		}
+	}
+
+	/**
+	 * 长度或填充不符: 使用 KW 包装人为构造的 AIV || P
+	 */
+	for _, aiv := range []string{
+		"a65959a600000000", // MLI = 0
+		"a65959a600000011", // MLI > 8n
+		"a65959a600000007", // MLI <= 8(n-1)
+		"a65959a60000000f", // 填充非0
+	} {
+		data := mustHex(aiv + "0102030405060708090a0b0c0d0e0f10")
+		wrap(b, data)
+		if _, err := UnwrapWithPadding(b, data); err != IntegrityError("KWP") {
+			t.Fatal(aiv, "got", err)
+		}
+	}
+	data := mustHex("a65959a6000000100102030405060708090a0b0c0d0e0f10")
+	wrap(b, data)
+	if _, err := UnwrapWithPadding(b, data); err != nil {
+		t.Fatal("valid construction rejected:", err)
+	}
+
+	if IntegrityError("KW").Error() != "keywrap: KW integrity check failed" {
+		t.Fatal("unexpected message")
+	}
+}
+
+type desLike struct {
+	cipher.Block
+}
+
+func (desLike) BlockSize() int { return 8 }
+
+func TestInvalidLengths(t *testing.T) {
+	b, _ := aes.NewCipher(make([]byte, 16))
+	for _, n := range []int{0, 8, 15, 17} {
+		if _, err := Wrap(b, make([]byte, n)); err == nil {
+			t.Fatal("KW accepted key length", n)
+		}
+	}
+	for _, n := range []int{0, 16, 23, 25} {
+		if _, err := Unwrap(b, make([]byte, n)); err == nil {
+			t.Fatal("KW accepted wrapped length", n)
+		}
+	}
+	if _, err := WrapWithPadding(b, nil); err == nil {
+		t.Fatal("KWP accepted empty key")
+	}
+	for _, n := range []int{0, 8, 15, 17} {
+		if _, err := UnwrapWithPadding(b, make([]byte, n)); err == nil {
+			t.Fatal("KWP accepted wrapped length", n)
+		}
+	}
+	if _, err := Wrap(desLike{b}, make([]byte, 16)); err != errBlockSize {
+		t.Fatal("64-bit block cipher accepted")
+	}
+}
@@ -0,0 +1,49 @@
+package mac
+
+import (
+	"crypto/cipher"
+	"hash"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/sm4"
+)
+
+/**
+ * CBC-MAC (GB/T 15852.1 MAC 算法1，填充方式1)
+ * 末尾补0至整块，空消息补一个全0分组
+ * 只对定长消息安全，新的应用应使用 CMAC
+ */
+type cbcMAC struct {
+	chain
+}
+
+//NewCBCMAC - 基于任意分组密码创建 CBC-MAC
+func NewCBCMAC(b cipher.Block) hash.Hash {
+	return &cbcMAC{newChain(b)}
+}
+
+//NewSM4CBCMAC - 创建 SM4 CBC-MAC
+func NewSM4CBCMAC(key []byte) (hash.Hash, error) {
+	b, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCBCMAC(b), nil
+}
+
+//NewAESCBCMAC - 创建 AES CBC-MAC
+func NewAESCBCMAC(key []byte) (hash.Hash, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCBCMAC(b), nil
+}
+
+func (m *cbcMAC) Sum(in []byte) []byte {
+	x := append([]byte{}, m.x...)
+	last := make([]byte, len(x))
+	copy(last, m.buf[:m.n])
+	m.process(x, last)
+	return append(in, x...)
+}
@@ -0,0 +1,89 @@
+package mac
+
+import (
+	"crypto/cipher"
+	"errors"
+	"hash"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/sm4"
+)
+
+/**
+ * CMAC (NIST SP 800-38B, RFC 4493, GB/T 15852.1 MAC 算法5)
+ * 子密钥 L = E(K, 0)，K1 = L·x，K2 = K1·x
+ * - 最后一个分组完整时与 K1 异或
+ * - 否则补 0x80 及若干 0x00 后与 K2 异或
+ */
+type cmac struct {
+	chain
+	k1, k2 []byte
+}
+
+//NewCMAC - 基于分组长度为 64 或 128 比特的分组密码创建 CMAC
+func NewCMAC(b cipher.Block) (hash.Hash, error) {
+	var rb byte
+	switch b.BlockSize() {
+	case 8:
+		rb = 0x1B
+	case 16:
+		rb = 0x87
+	default:
+		return nil, errors.New("mac: CMAC requires a 64-bit or 128-bit block cipher")
+	}
+
+	m := &cmac{chain: newChain(b)}
+	l := make([]byte, b.BlockSize())
+	b.Encrypt(l, l)
+	m.k1 = shiftLeft(l, rb)
+	m.k2 = shiftLeft(m.k1, rb)
+	return m, nil
+}
+
+//NewSM4CMAC - 创建 SM4-CMAC
+func NewSM4CMAC(key []byte) (hash.Hash, error) {
+	b, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCMAC(b)
+}
+
+//NewAESCMAC - 创建 AES-CMAC
+func NewAESCMAC(key []byte) (hash.Hash, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCMAC(b)
+}
+
+/**
+ * GF(2^n) 上乘 x，最高位为1时异或约简值 rb，不依赖于 L 的值分支
+ */
+func shiftLeft(in []byte, rb byte) []byte {
+	out := make([]byte, len(in))
+	msb := in[0] >> 7
+	for i := 0; i < len(in)-1; i++ {
+		out[i] = in[i]<<1 | in[i+1]>>7
+	}
+	out[len(in)-1] = in[len(in)-1]<<1 ^ rb&-msb
+	return out
+}
+
+func (m *cmac) Sum(in []byte) []byte {
+	x := append([]byte{}, m.x...)
+	last := make([]byte, len(x))
+	copy(last, m.buf[:m.n])
+
+	k := m.k1
+	if m.n < len(last) {
+		last[m.n] = 0x80
+		k = m.k2
+	}
+	for i := range last {
+		last[i] ^= k[i]
+	}
+	m.process(x, last)
+	return append(in, x...)
+}
@@ -0,0 +1,191 @@
+package mac
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"github.com/anhk/crypto/sm4"
+)
+
+/**
+ * GB/T 15852.1-2020 (ISO/IEC 9797-1:2011) 基于 SM4 的 MAC 算法 1 ~ 6
+ *
+ * 填充后的数据 D1 ... Dq 按 CBC 方式迭代: H0 = 0，Hi = E(K, Hi-1 ^ Di)，之后:
+ *   算法1: G = Hq                              密钥 K
+ *   算法2: G = E(K', Hq)                       密钥 K, K'
+ *   算法3: G = E(K, D(K', Hq))                 密钥 K, K'
+ *   算法4: H1 = E(K'', E(K, D1))，G = E(K', Hq)  密钥 K, K', K''，q 至少为 2
+ *   算法5: CMAC，使用其自身的填充              密钥 K
+ *   算法6: Hq = E(K', Hq-1 ^ Dq)，G = Hq        密钥 K, K'
+ * MAC 为 G 最左边的 tagSize 字节
+ *
+ * 各密钥应相互独立，标准允许从 K 派生 K' 和 K''，派生方式由调用者按所用规范选择
+ */
+
+//Algorithm - GB/T 15852.1 MAC 算法编号
+type Algorithm int
+
+const (
+	Algorithm1 Algorithm = 1 + iota
+	Algorithm2
+	Algorithm3
+	Algorithm4
+	Algorithm5
+	Algorithm6
+)
+
+//PaddingMethod - GB/T 15852.1 填充方式，算法5 不使用，须为 0
+type PaddingMethod int
+
+const (
+	//PaddingMethod1 - 补0至整块，空数据补一个全0分组
+	PaddingMethod1 PaddingMethod = 1 + iota
+
+	//PaddingMethod2 - 补一个 0x80 后补0至整块
+	PaddingMethod2
+
+	//PaddingMethod3 - 数据按方式1补0(空数据不补)，之前加一个分组，内容为数据的比特长度(大端)
+	PaddingMethod3
+)
+
+const (
+	MinTagSize = 4
+	MaxTagSize = sm4.BlockSize
+)
+
+var errTooShort = errors.New("mac: algorithm 4 requires at least two blocks of padded data")
+
+//GB15852 - 可以并发使用
+type GB15852 struct {
+	alg     Algorithm
+	pad     PaddingMethod
+	tagSize int
+
+	k, k1, k2 cipher.Block
+	cmac      *cmac
+}
+
+//NewGB15852 - 创建 GB/T 15852.1 MAC，keys 依次为 K、K'、K''，个数由算法决定
+func NewGB15852(alg Algorithm, pad PaddingMethod, tagSize int, keys ...[]byte) (*GB15852, error) {
+	nkeys := 0
+	switch alg {
+	case Algorithm1, Algorithm5:
+		nkeys = 1
+	case Algorithm2, Algorithm3, Algorithm6:
+		nkeys = 2
+	case Algorithm4:
+		nkeys = 3
+	default:
+		return nil, errors.New("mac: unknown GB/T 15852.1 algorithm")
+	}
+	if len(keys) != nkeys {
+		return nil, errors.New("mac: wrong number of keys for GB/T 15852.1 algorithm")
+	}
+
+	if alg == Algorithm5 {
+		if pad != 0 {
+			return nil, errors.New("mac: algorithm 5 uses its own padding")
+		}
+	} else if pad < PaddingMethod1 || pad > PaddingMethod3 {
+		return nil, errors.New("mac: unknown GB/T 15852.1 padding method")
+	}
+
+	if tagSize < MinTagSize || tagSize > MaxTagSize {
+		return nil, errors.New("mac: invalid tag size")
+	}
+
+	m := &GB15852{alg: alg, pad: pad, tagSize: tagSize}
+	blocks := []*cipher.Block{&m.k, &m.k1, &m.k2}
+	for i, key := range keys {
+		b, err := sm4.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		*blocks[i] = b
+	}
+
+	if alg == Algorithm5 {
+		h, _ := NewCMAC(m.k)
+		m.cmac = h.(*cmac)
+	}
+	return m, nil
+}
+
+//Size - MAC 的长度
+func (m *GB15852) Size() int {
+	return m.tagSize
+}
+
+//MAC - 计算 data 的 MAC，只有算法4 在填充后的数据不足两个分组时返回错误
+func (m *GB15852) MAC(data []byte) ([]byte, error) {
+	if m.alg == Algorithm5 {
+		c := *m.cmac
+		c.chain = newChain(m.k)
+		c.Write(data)
+		return c.Sum(nil)[:m.tagSize], nil
+	}
+
+	padded := m.padding(data)
+	q := len(padded) / sm4.BlockSize
+	if m.alg == Algorithm4 && q < 2 {
+		return nil, errTooShort
+	}
+
+	h := make([]byte, sm4.BlockSize)
+	for i := 0; i < q; i++ {
+		d := padded[i*sm4.BlockSize : (i+1)*sm4.BlockSize]
+		for j := range h {
+			h[j] ^= d[j]
+		}
+
+		switch {
+		case i == q-1 && m.alg == Algorithm6:
+			m.k1.Encrypt(h, h)
+		case i == 0 && m.alg == Algorithm4:
+			m.k.Encrypt(h, h)
+			m.k2.Encrypt(h, h)
+		default:
+			m.k.Encrypt(h, h)
+		}
+	}
+
+	switch m.alg {
+	case Algorithm2, Algorithm4:
+		m.k1.Encrypt(h, h)
+	case Algorithm3:
+		m.k1.Decrypt(h, h)
+		m.k.Encrypt(h, h)
+	}
+	return h[:m.tagSize], nil
+}
+
+//Verify - 以常数时间比较 data 的 MAC 与 tag
+func (m *GB15852) Verify(data, tag []byte) bool {
+	expected, err := m.MAC(data)
+	return err == nil && Verify(tag, expected)
+}
+
+func (m *GB15852) padding(data []byte) []byte {
+	const bs = sm4.BlockSize
+	n := len(data)
+	switch m.pad {
+	case PaddingMethod1:
+		if n == 0 {
+			return make([]byte, bs)
+		}
+		out := make([]byte, (n+bs-1)/bs*bs)
+		copy(out, data)
+		return out
+	case PaddingMethod2:
+		out := make([]byte, (n/bs+1)*bs)
+		copy(out, data)
+		out[n] = 0x80
+		return out
+	default:
+		out := make([]byte, bs+(n+bs-1)/bs*bs)
+		binary.BigEndian.PutUint64(out[bs-8:bs], uint64(n)*8)
+		copy(out[bs:], data)
+		return out
+	}
+}
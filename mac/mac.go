@@ -0,0 +1,72 @@
+package mac
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+/**
+ * 基于分组密码的消息鉴别码 (GB/T 15852.1, ISO/IEC 9797-1)
+ * 所有实现都满足 hash.Hash 接口，Sum 不改变内部状态，可以继续写入
+ */
+
+//Verify - 以常数时间比较两个 MAC，长度不同时返回 false
+func Verify(mac, expectedMAC []byte) bool {
+	return subtle.ConstantTimeCompare(mac, expectedMAC) == 1
+}
+
+/**
+ * CBC 链接: H = E(K, H ^ D)，初始值为0
+ * 最后一个分组(可能是完整分组)保留在 buf 中，直到有后续数据时才处理，
+ * 以便 Sum 时对最后一个分组做不同的处理
+ */
+type chain struct {
+	b   cipher.Block
+	x   []byte
+	buf []byte
+	n   int
+}
+
+func newChain(b cipher.Block) chain {
+	bs := b.BlockSize()
+	return chain{b: b, x: make([]byte, bs), buf: make([]byte, bs)}
+}
+
+func (c *chain) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if c.n == len(c.buf) {
+			c.process(c.x, c.buf)
+			c.n = 0
+		}
+		k := copy(c.buf[c.n:], p)
+		c.n += k
+		p = p[k:]
+	}
+	return written, nil
+}
+
+/**
+ * x = E(K, x ^ block)
+ */
+func (c *chain) process(x, block []byte) {
+	for i := range x {
+		x[i] ^= block[i]
+	}
+	c.b.Encrypt(x, x)
+}
+
+func (c *chain) Reset() {
+	for i := range c.x {
+		c.x[i] = 0
+	}
+	c.n = 0
+}
+
+func (c *chain) Size() int {
+	return len(c.x)
+}
+
+func (c *chain) BlockSize() int {
+	return len(c.x)
+}
@@ -0,0 +1,405 @@
+package mac
+
+import (
+	"bytes"
+	stdaes "crypto/aes"
+	"crypto/des"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * NIST SP 800-38B 附录D.1 ~ D.3 的消息，依次取前 0, 16, 40, 64 字节
+ */
+var message = mustHex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+	"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+
+var messageLengths = []int{0, 16, 40, 64}
+
+type macTest struct {
+	key  string
+	tags []string
+}
+
+/**
+ * NIST SP 800-38B 附录D.1 ~ D.3 (AES-128/192/256)
+ */
+var aesCMACTests = []macTest{
+	{
+		"2b7e151628aed2a6abf7158809cf4f3c",
+		[]string{
+			"bb1d6929e95937287fa37d129b756746", "070a16b46b4d4144f79bdd9dd04a287c",
+			"dfa66747de9ae63030ca32611497c827", "51f0bebf7e3b9d92fc49741779363cfe",
+		},
+	},
+	{
+		"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+		[]string{
+			"d17ddf46adaacde531cac483de7a9367", "9e99a7bf31e710900662f65e617c5184",
+			"8a1de5be2eb31aad089a82e6ee908b0e", "a1d5df0eed790f794d77589659f39a11",
+		},
+	},
+	{
+		"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+		[]string{
+			"028962f61b7bf89efc6b551f4667d983", "28a7023f452e8f82bd4bf28d8c37c35c",
+			"aaf3d8f1de5640c232f5b169b9c911e6", "e1992190549f6ed5696a2c056c315410",
+		},
+	},
+}
+
+/**
+ * SM4 与 AES-128 使用相同的密钥和消息
+ * CMAC 与 `openssl mac -cipher SM4-CBC ... CMAC` 一致
+ * CBC-MAC 与 OpenSSL 零 IV 的 CBC 加密结果的最后一个分组一致
+ */
+var sm4CMACTest = macTest{
+	"2b7e151628aed2a6abf7158809cf4f3c",
+	[]string{
+		"399a9c930964a3d4e38c59da47f0b309", "4e4c2a4417e567fef081e0fab55a5762",
+		"8e31701927d50b28d53787513b69dd75", "cc2b4f3d2c5aaf8a4ac30e28650eddc0",
+	},
+}
+
+var sm4CBCMACTest = macTest{
+	"2b7e151628aed2a6abf7158809cf4f3c",
+	[]string{
+		"09cbe15d851b5b0bbba4ca42eae3ff70", "a51411ff04a711443891fce7ab842a29",
+		"f5b8a40c05fd4a65398e6efe580c1dfb", "d9d6e7e4ce6a50a4e1743577ffd22f20",
+	},
+}
+
+var aesCBCMACTest = macTest{
+	"2b7e151628aed2a6abf7158809cf4f3c",
+	[]string{
+		"7df76b0c1ab899b33e42f047b91b546f", "3ad77bb40d7a3660a89ecaf32466ef97",
+		"07d192e3e6f099edcc39fde6d09c762d", "a7356e1207bb406639e5e5ceb9a9ed93",
+	},
+}
+
+func checkMAC(t *testing.T, name string, newMAC func([]byte) (hash.Hash, error), test macTest) {
+	t.Helper()
+	h, err := newMAC(mustHex(test.key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range messageLengths {
+		h.Reset()
+		h.Write(message[:n])
+		if tag := h.Sum(nil); hex.EncodeToString(tag) != test.tags[i] {
+			t.Fatalf("%s: length %d: got %x", name, n, tag)
+		}
+	}
+}
+
+func TestCMAC(t *testing.T) {
+	for _, test := range aesCMACTests {
+		checkMAC(t, "AES-CMAC", NewAESCMAC, test)
+	}
+	checkMAC(t, "SM4-CMAC", NewSM4CMAC, sm4CMACTest)
+}
+
+func TestCBCMAC(t *testing.T) {
+	checkMAC(t, "SM4 CBC-MAC", NewSM4CBCMAC, sm4CBCMACTest)
+	checkMAC(t, "AES CBC-MAC", NewAESCBCMAC, aesCBCMACTest)
+}
+
+/**
+ * NIST SP 800-38B 附录D.1 子密钥
+ */
+func TestSubkeys(t *testing.T) {
+	b, _ := stdaes.NewCipher(mustHex("2b7e151628aed2a6abf7158809cf4f3c"))
+	h, _ := NewCMAC(b)
+	m := h.(*cmac)
+	if hex.EncodeToString(m.k1) != "fbeed618357133667c85e08f7236a8de" ||
+		hex.EncodeToString(m.k2) != "f7ddac306ae266ccf90bc11ee46d513b" {
+		t.Fatalf("invalid subkeys %x %x", m.k1, m.k2)
+	}
+}
+
+/**
+ * 分多次写入、Sum 之后继续写入、Reset 之后重新计算，结果与一次写入相同
+ */
+func TestStreaming(t *testing.T) {
+	for _, newMAC := range []func([]byte) (hash.Hash, error){NewSM4CMAC, NewSM4CBCMAC, NewAESCMAC, NewAESCBCMAC} {
+		h, _ := newMAC(mustHex("2b7e151628aed2a6abf7158809cf4f3c"))
+		if h.Size() != 16 || h.BlockSize() != 16 {
+			t.Fatal("unexpected sizes")
+		}
+
+		for n := 0; n <= len(message); n++ {
+			h.Reset()
+			h.Write(message[:n])
+			expected := h.Sum(nil)
+
+			for step := 1; step <= 17; step += 4 {
+				h.Reset()
+				for i := 0; i < n; i += step {
+					end := i + step
+					if end > n {
+						end = n
+					}
+					h.Write(message[i:end])
+					h.Sum(nil)
+				}
+				if got := h.Sum([]byte("prefix")); !bytes.Equal(got[6:], expected) || string(got[:6]) != "prefix" {
+					t.Fatal("streaming mismatch, length", n, "step", step)
+				}
+			}
+		}
+	}
+}
+
+/**
+ * NIST SP 800-38B 附录D.4 (三密钥 TDEA)，64比特分组
+ */
+func TestCMAC64(t *testing.T) {
+	b, _ := des.NewTripleDESCipher(mustHex("8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5"))
+	h, err := NewCMAC(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range []int{0, 16, 20, 32} {
+		h.Reset()
+		h.Write(message[:n])
+		expected := []string{"b7a688e122ffaf95", "286d394673448197", "743ddbe0ce2dc2ed", "33e6b1092400eae5"}[i]
+		if tag := h.Sum(nil); hex.EncodeToString(tag) != expected {
+			t.Fatalf("length %d: got %x", n, tag)
+		}
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	if _, err := NewSM4CMAC(make([]byte, 15)); err == nil {
+		t.Fatal("invalid SM4 key accepted")
+	}
+	if _, err := NewAESCBCMAC(make([]byte, 15)); err == nil {
+		t.Fatal("invalid AES key accepted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	h, _ := NewSM4CMAC(mustHex(sm4CMACTest.key))
+	h.Write(message)
+	tag := h.Sum(nil)
+	if !Verify(tag, mustHex(sm4CMACTest.tags[3])) {
+		t.Fatal("valid tag rejected")
+	}
+
+	for i := range tag {
+		bad := append([]byte{}, tag...)
+		bad[i] ^= 1
+		if Verify(bad, tag) {
+			t.Fatal("modified tag accepted")
+		}
+	}
+	if Verify(tag[:15], tag) || Verify(nil, tag) {
+		t.Fatal("tag of different length accepted")
+	}
+}
+
+func BenchmarkSM4CMAC(b *testing.B) {
+	h, _ := NewSM4CMAC(make([]byte, 16))
+	buf := make([]byte, 1024)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(buf)
+		h.Sum(nil)
+	}
+}
+
+/**
+ * ISO/IEC 9797-1 附录B 的消息，换用 128 比特的 SM4 密钥 K、K'、K''
+ * 消息依次为 "Now is the time for all " 的前 0、16 字节，"Now is the time for it"，"Now is the time for all "
+ * 期望值由只使用 OpenSSL SM4-ECB/SM4-CBC 的脚本计算: sh testdata/gb15852.sh
+ * "" 表示算法4 数据不足两个分组
+ */
+var gbKeys = [][]byte{
+	mustHex("0123456789abcdeffedcba9876543210"),
+	mustHex("fedcba98765432100123456789abcdef"),
+	mustHex("00112233445566778899aabbccddeeff"),
+}
+
+var gbTests = []struct {
+	alg  Algorithm
+	pad  PaddingMethod
+	tags [4]string
+}{
+	{Algorithm1, PaddingMethod1, [4]string{
+		"2677f46b09c122cc975533105bd4a22a", "081e4dc9828c5265aa4410e1a38607d4",
+		"5c3a3366e55b7c9f190911ba04a8b636", "735a13d12d64588bb0df793f2103c557",
+	}},
+	{Algorithm1, PaddingMethod2, [4]string{
+		"8c338e5a27e349beae39214feda97099", "71d2165193af4e0a32e18e06b93f9d43",
+		"6715958a5784bc53ff95377398dbe6cc", "d3cfa47f45cc36f3d6fc35214380ee74",
+	}},
+	{Algorithm1, PaddingMethod3, [4]string{
+		"2677f46b09c122cc975533105bd4a22a", "8664890a47992bf079accbd880b43a11",
+		"a2ba2d58eee27bda145c895c3153d0da", "a99e08193951b64744131a3f3508b7d8",
+	}},
+	{Algorithm2, PaddingMethod1, [4]string{
+		"9fb09a3987dad890df3065f01bb587d4", "230fbacb6d79fa4df71f19c94907ac7c",
+		"1610a42fbcff4ea5b29522e731e40640", "bc092236711b9db9f61d2572b0744228",
+	}},
+	{Algorithm2, PaddingMethod2, [4]string{
+		"2d461797ed9bc9bf0c1cc829f3e1374b", "11eda6f8a9a661d952967b525cc8f6df",
+		"c17d589a3683df17d4caa22361407e6e", "d03f4e7bfdf7acb7e9e8638090f465b6",
+	}},
+	{Algorithm2, PaddingMethod3, [4]string{
+		"9fb09a3987dad890df3065f01bb587d4", "ebd65922311d67030441b63be64bb894",
+		"7d50e905ff12a21e0060975c3663760a", "4eabaf2ef411e33a7053dba9b2157258",
+	}},
+	{Algorithm3, PaddingMethod1, [4]string{
+		"c17dec089dec65b3b6ebd355b62f3990", "05d1d4304f4c50feff79cb46908179b8",
+		"afa2b77172528dbb08c9f392115f79d0", "e81372484ccad3dd48a7cdbdd8111947",
+	}},
+	{Algorithm3, PaddingMethod2, [4]string{
+		"0f64284e14a6b71446c3366506468d40", "b86cced45a5e26d9d9e7caaa3917d835",
+		"32e61cf73569eeaf0b3f7d3acb450989", "beed908e825960a72f75d532d4cc581e",
+	}},
+	{Algorithm3, PaddingMethod3, [4]string{
+		"c17dec089dec65b3b6ebd355b62f3990", "84035caeba3e0e95a03d5ec739f79c1b",
+		"b5dee95ed9c117225688d8321f6fdeb5", "d94702db62432a95c7700aa6c817c87a",
+	}},
+	{Algorithm4, PaddingMethod1, [4]string{
+		"", "",
+		"a27e38582477aa47c06645d36ee43e79", "a833ddbb668c401d5eafd91276a5edfa",
+	}},
+	{Algorithm4, PaddingMethod2, [4]string{
+		"", "7046c7ad03daece9f39582f5d662be60",
+		"b7ea55df035d14b5140d16e1b4aec3a7", "0a00a67ebad0bc12f42201cda018ccbe",
+	}},
+	{Algorithm4, PaddingMethod3, [4]string{
+		"", "c7feecb3bed596a25d049f825e55bd5e",
+		"7cfa91e922ce0c16bd0429fc6b817927", "66256c7c772fa395a1eeef2713e6d834",
+	}},
+	{Algorithm6, PaddingMethod1, [4]string{
+		"400133569e9cc52a4d9321cd2221550f", "12137a0d877d78b06aad5f78bea8cfbc",
+		"a6e3cb7ab73909dc4439c21f7cc8e1b0", "e22b218299d15806468695d1836e2b50",
+	}},
+	{Algorithm6, PaddingMethod2, [4]string{
+		"455346136de56a09a3b1a33ae641e709", "4b909e06a1877ca2509b83f3cb369048",
+		"692b2376201b1a104ac78c3e49755c45", "5afe8da9737801f837a161ddd3707829",
+	}},
+	{Algorithm6, PaddingMethod3, [4]string{
+		"400133569e9cc52a4d9321cd2221550f", "c50fff03afa7357bb58d62180e14e3a9",
+		"9478d635481927b837a721fcfa3548b6", "aa6f64c1aa9c02c3220b5dc05587fc11",
+	}},
+}
+
+func gbKeysFor(alg Algorithm) [][]byte {
+	switch alg {
+	case Algorithm1, Algorithm5:
+		return gbKeys[:1]
+	case Algorithm4:
+		return gbKeys
+	}
+	return gbKeys[:2]
+}
+
+func TestGB15852(t *testing.T) {
+	msgs := [][]byte{
+		[]byte(""),
+		[]byte("Now is the time "),
+		[]byte("Now is the time for it"),
+		[]byte("Now is the time for all "),
+	}
+
+	for _, test := range gbTests {
+		for _, tagSize := range []int{MaxTagSize, 8, MinTagSize} {
+			m, err := NewGB15852(test.alg, test.pad, tagSize, gbKeysFor(test.alg)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Size() != tagSize {
+				t.Fatal("unexpected size")
+			}
+
+			for i, msg := range msgs {
+				tag, err := m.MAC(msg)
+				if test.tags[i] == "" {
+					if err != errTooShort {
+						t.Fatal("algorithm 4 accepted a single block")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hex.EncodeToString(tag) != test.tags[i][:2*tagSize] {
+					t.Fatalf("algorithm %d, padding %d, message %q: got %x", test.alg, test.pad, msg, tag)
+				}
+				if !m.Verify(msg, tag) {
+					t.Fatal("valid tag rejected")
+				}
+				tag[0] ^= 1
+				if m.Verify(msg, tag) {
+					t.Fatal("modified tag accepted")
+				}
+			}
+		}
+	}
+}
+
+/**
+ * 算法1 + 填充方式1 即 CBC-MAC，算法5 即 CMAC
+ */
+func TestGB15852Equivalence(t *testing.T) {
+	m1, _ := NewGB15852(Algorithm1, PaddingMethod1, 16, mustHex(sm4CBCMACTest.key))
+	m5, _ := NewGB15852(Algorithm5, 0, 16, mustHex(sm4CMACTest.key))
+	for i, n := range messageLengths {
+		if tag, _ := m1.MAC(message[:n]); hex.EncodeToString(tag) != sm4CBCMACTest.tags[i] {
+			t.Fatal("algorithm 1 differs from CBC-MAC, length", n)
+		}
+		if tag, _ := m5.MAC(message[:n]); hex.EncodeToString(tag) != sm4CMACTest.tags[i] {
+			t.Fatal("algorithm 5 differs from CMAC, length", n)
+		}
+	}
+
+	m5, _ = NewGB15852(Algorithm5, 0, 4, mustHex(sm4CMACTest.key))
+	if tag, _ := m5.MAC(message); hex.EncodeToString(tag) != sm4CMACTest.tags[3][:8] {
+		t.Fatal("truncated CMAC mismatch")
+	}
+}
+
+func TestGB15852Invalid(t *testing.T) {
+	key := gbKeys[0]
+	tests := []struct {
+		alg     Algorithm
+		pad     PaddingMethod
+		tagSize int
+		keys    [][]byte
+	}{
+		{0, PaddingMethod1, 16, [][]byte{key}},
+		{7, PaddingMethod1, 16, [][]byte{key}},
+		{Algorithm1, 0, 16, [][]byte{key}},
+		{Algorithm1, 4, 16, [][]byte{key}},
+		{Algorithm5, PaddingMethod2, 16, [][]byte{key}},
+		{Algorithm1, PaddingMethod1, 3, [][]byte{key}},
+		{Algorithm1, PaddingMethod1, 17, [][]byte{key}},
+		{Algorithm1, PaddingMethod1, 16, [][]byte{key, key}},
+		{Algorithm2, PaddingMethod1, 16, [][]byte{key}},
+		{Algorithm4, PaddingMethod1, 16, [][]byte{key, key}},
+		{Algorithm3, PaddingMethod1, 16, [][]byte{key, key[:15]}},
+	}
+	for i, test := range tests {
+		if _, err := NewGB15852(test.alg, test.pad, test.tagSize, test.keys...); err == nil {
+			t.Fatal("case", i, "accepted")
+		}
+	}
+
+	m, _ := NewGB15852(Algorithm4, PaddingMethod1, 8, gbKeys...)
+	if m.Verify(nil, make([]byte, 8)) {
+		t.Fatal("algorithm 4 verified a short message")
+	}
+}
@@ -0,0 +1,79 @@
+#!/bin/sh
+# GB/T 15852.1 (ISO/IEC 9797-1) MAC 算法 1 ~ 4、6 的期望值，只使用 OpenSSL 的 SM4 分组运算计算
+# 消息为 ISO/IEC 9797-1 附录B 的 "Now is the time for all " 的前 0、16 字节、
+# "Now is the time for it" 以及 "Now is the time for all "，密钥换为 128 比特的 SM4 密钥
+#
+#   sh testdata/gb15852.sh
+set -e
+
+K=0123456789abcdeffedcba9876543210
+K1=fedcba98765432100123456789abcdef
+K2=00112233445566778899aabbccddeeff
+ZERO=00000000000000000000000000000000
+
+hex() { xxd -p | tr -d '\n'; }
+
+# E(key, block) / D(key, block)
+enc() { printf '%s' "$2" | xxd -r -p | openssl enc -sm4-ecb -nopad -K "$1" | hex; }
+dec() { printf '%s' "$2" | xxd -r -p | openssl enc -d -sm4-ecb -nopad -K "$1" | hex; }
+
+# CBC 加密的最后一个分组，参数为 key iv data
+cbc() {
+	if [ -z "$3" ]; then
+		printf '%s' "$2"
+		return
+	fi
+	printf '%s' "$3" | xxd -r -p | openssl enc -sm4-cbc -nopad -K "$1" -iv "$2" | hex | tail -c 32
+}
+
+# 填充方式 1、2、3，参数为 method data(hex)
+pad() {
+	d=$2
+	case $1 in
+	1)
+		[ -z "$d" ] && d=$ZERO
+		while [ $((${#d} % 32)) -ne 0 ]; do d=${d}00; done
+		;;
+	2)
+		d=${d}80
+		while [ $((${#d} % 32)) -ne 0 ]; do d=${d}00; done
+		;;
+	3)
+		while [ $((${#d} % 32)) -ne 0 ]; do d=${d}00; done
+		d=$(printf '%032x' $((${#2} * 4)))$d
+		;;
+	esac
+	printf '%s' "$d"
+}
+
+mac() {
+	d=$(pad "$2" "$3")
+	case $1 in
+	1) cbc $K $ZERO "$d" ;;
+	2) enc $K1 "$(cbc $K $ZERO "$d")" ;;
+	3) enc $K "$(dec $K1 "$(cbc $K $ZERO "$d")")" ;;
+	4)
+		if [ ${#d} -lt 64 ]; then
+			printf '""'
+			return
+		fi
+		h1=$(enc $K2 "$(enc $K "$(printf '%s' "$d" | head -c 32)")")
+		enc $K1 "$(cbc $K "$h1" "$(printf '%s' "$d" | tail -c +33)")"
+		;;
+	6)
+		n=$((${#d} - 32))
+		h=$(cbc $K $ZERO "$(printf '%s' "$d" | head -c $n)")
+		cbc $K1 "$h" "$(printf '%s' "$d" | tail -c 32)"
+		;;
+	esac
+}
+
+for alg in 1 2 3 4 6; do
+	for p in 1 2 3; do
+		printf 'Algorithm%s, PaddingMethod%s:' $alg $p
+		for msg in "" "Now is the time " "Now is the time for it" "Now is the time for all "; do
+			printf ' %s' "$(mac $alg $p "$(printf '%s' "$msg" | hex)")"
+		done
+		echo
+	done
+done
@@ -0,0 +1,187 @@
+package padding
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+//ErrInvalidPadding - 所有去填充失败都返回同一个错误，避免成为填充预言(padding oracle)
+var ErrInvalidPadding = errors.New("padding: invalid padding")
+
+//Padding - 分组填充方式，blockSize 为 1 ~ 255 字节
+type Padding interface {
+	/**
+	 * 返回填充后的新切片，不修改 src
+	 */
+	Pad(src []byte, blockSize int) []byte
+
+	/**
+	 * 返回去掉填充后的 src 子切片，src 的长度必须为 blockSize 的整数倍
+	 * 对填充内容的检查与填充长度无关，耗时恒定
+	 */
+	Unpad(src []byte, blockSize int) ([]byte, error)
+}
+
+var (
+	//PKCS7 - PKCS#7: 填充 n 个值为 n 的字节
+	PKCS7 Padding = pkcs7{}
+
+	//ISO7816 - ISO/IEC 7816-4: 填充 0x80 后接若干 0x00
+	ISO7816 Padding = iso7816{}
+
+	//X923 - ANSI X9.23: 填充若干 0x00，最后一个字节为填充长度
+	X923 Padding = x923{}
+
+	//Zero - 填充 0x00 至整块，已对齐时不填充；去填充时去掉末块中结尾的 0x00，
+	// 因此只适用于不以 0x00 结尾的数据
+	Zero Padding = zero{}
+)
+
+func checkBlockSize(blockSize int) {
+	if blockSize < 1 || blockSize > 255 {
+		panic("padding: invalid block size")
+	}
+}
+
+/**
+ * 填充长度 n = blockSize - len(src) % blockSize，范围 1 ~ blockSize
+ */
+func pad(src []byte, blockSize int) ([]byte, int) {
+	checkBlockSize(blockSize)
+	n := blockSize - len(src)%blockSize
+	out := make([]byte, len(src)+n)
+	copy(out, src)
+	return out, n
+}
+
+func checkLength(src []byte, blockSize int) bool {
+	checkBlockSize(blockSize)
+	return len(src) > 0 && len(src)%blockSize == 0
+}
+
+type pkcs7 struct{}
+
+func (pkcs7) Pad(src []byte, blockSize int) []byte {
+	out, n := pad(src, blockSize)
+	for i := len(src); i < len(out); i++ {
+		out[i] = byte(n)
+	}
+	return out
+}
+
+func (pkcs7) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if !checkLength(src, blockSize) {
+		return nil, ErrInvalidPadding
+	}
+
+	n := int(src[len(src)-1])
+	good := subtle.ConstantTimeLessOrEq(1, n) & subtle.ConstantTimeLessOrEq(n, blockSize)
+
+	/**
+	 * 检查末块的每个字节: 位于填充范围内的字节必须等于 n
+	 */
+	for i := 0; i < blockSize; i++ {
+		b := src[len(src)-1-i]
+		inPad := subtle.ConstantTimeLessOrEq(i+1, n)
+		good &= subtle.ConstantTimeSelect(inPad, subtle.ConstantTimeByteEq(b, byte(n)), 1)
+	}
+
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return src[:len(src)-n], nil
+}
+
+type x923 struct{}
+
+func (x923) Pad(src []byte, blockSize int) []byte {
+	out, n := pad(src, blockSize)
+	out[len(out)-1] = byte(n)
+	return out
+}
+
+func (x923) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if !checkLength(src, blockSize) {
+		return nil, ErrInvalidPadding
+	}
+
+	n := int(src[len(src)-1])
+	good := subtle.ConstantTimeLessOrEq(1, n) & subtle.ConstantTimeLessOrEq(n, blockSize)
+
+	/**
+	 * 长度字节之前、位于填充范围内的字节必须为 0x00
+	 */
+	for i := 1; i < blockSize; i++ {
+		b := src[len(src)-1-i]
+		inPad := subtle.ConstantTimeLessOrEq(i+1, n)
+		good &= subtle.ConstantTimeSelect(inPad, subtle.ConstantTimeByteEq(b, 0), 1)
+	}
+
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return src[:len(src)-n], nil
+}
+
+type iso7816 struct{}
+
+func (iso7816) Pad(src []byte, blockSize int) []byte {
+	out, _ := pad(src, blockSize)
+	out[len(src)] = 0x80
+	return out
+}
+
+func (iso7816) Unpad(src []byte, blockSize int) ([]byte, error) {
+	if !checkLength(src, blockSize) {
+		return nil, ErrInvalidPadding
+	}
+
+	/**
+	 * 从末尾向前找到第一个非0字节，该字节必须为 0x80
+	 */
+	n, found, good := 0, 0, 0
+	for i := 0; i < blockSize; i++ {
+		b := src[len(src)-1-i]
+		isZero := subtle.ConstantTimeByteEq(b, 0)
+		first := (1 - found) & (1 - isZero)
+		n = subtle.ConstantTimeSelect(first, i+1, n)
+		good = subtle.ConstantTimeSelect(first, subtle.ConstantTimeByteEq(b, 0x80), good)
+		found |= first
+	}
+
+	if good&found != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return src[:len(src)-n], nil
+}
+
+type zero struct{}
+
+func (zero) Pad(src []byte, blockSize int) []byte {
+	checkBlockSize(blockSize)
+	n := (blockSize - len(src)%blockSize) % blockSize
+	out := make([]byte, len(src)+n)
+	copy(out, src)
+	return out
+}
+
+func (zero) Unpad(src []byte, blockSize int) ([]byte, error) {
+	checkBlockSize(blockSize)
+	if len(src)%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+	if len(src) == 0 {
+		return src, nil
+	}
+
+	/**
+	 * 计算末块结尾连续 0x00 的个数
+	 */
+	n, stop := 0, 0
+	for i := 0; i < blockSize; i++ {
+		isZero := subtle.ConstantTimeByteEq(src[len(src)-1-i], 0)
+		stop |= 1 - isZero
+		n += 1 - stop
+	}
+	return src[:len(src)-n], nil
+}
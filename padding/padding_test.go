@@ -0,0 +1,100 @@
+package padding
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+
+	"github.com/anhk/crypto/aes"
+	"github.com/anhk/crypto/sm4"
+)
+
+var padTests = []struct {
+	padding Padding
+	src     string
+	padded  string
+}{
+	{PKCS7, "", "0808080808080808"},
+	{PKCS7, "4142", "4142060606060606"},
+	{PKCS7, "4142434445464748", "41424344454647480808080808080808"},
+	{ISO7816, "", "8000000000000000"},
+	{ISO7816, "4142", "4142800000000000"},
+	{ISO7816, "41424344454647", "4142434445464780"},
+	{X923, "", "0000000000000008"},
+	{X923, "4142", "4142000000000006"},
+	{X923, "41424344454647", "4142434445464701"},
+	{Zero, "", ""},
+	{Zero, "4142", "4142000000000000"},
+	{Zero, "4142434445464748", "4142434445464748"},
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestPadUnpad(t *testing.T) {
+	for i, test := range padTests {
+		src := mustHex(test.src)
+		padded := test.padding.Pad(src, 8)
+		if hex.EncodeToString(padded) != test.padded {
+			t.Fatalf("#%d: invalid pad %x", i, padded)
+		}
+		out, err := test.padding.Unpad(padded, 8)
+		if err != nil || !bytes.Equal(out, src) {
+			t.Fatalf("#%d: invalid unpad", i)
+		}
+	}
+}
+
+var unpadErrorTests = []struct {
+	padding Padding
+	src     string
+}{
+	{PKCS7, ""},
+	{PKCS7, "41424344454647"},
+	{PKCS7, "4142434445464700"},
+	{PKCS7, "4142434445464709"},
+	{PKCS7, "4142434445460303"},
+	{ISO7816, ""},
+	{ISO7816, "0000000000000000"},
+	{ISO7816, "4142434445464748"},
+	{ISO7816, "4142434480000001"},
+	{X923, "4142434445464700"},
+	{X923, "4142434445464709"},
+	{X923, "4142434400010004"},
+	{Zero, "41424344454647"},
+}
+
+func TestUnpadError(t *testing.T) {
+	for i, test := range unpadErrorTests {
+		src := mustHex(test.src)
+		if _, err := test.padding.Unpad(src, 8); err != ErrInvalidPadding {
+			t.Fatalf("#%d: expected ErrInvalidPadding, got %v", i, err)
+		}
+	}
+}
+
+func TestCBC(t *testing.T) {
+	key := []byte{0x66, 0x0D, 0x16, 0xF4, 0xCC, 0x9E, 0x1E, 0xC5, 0x4F, 0xB1, 0x66, 0x0A, 0xBB, 0x97, 0xE6, 0x4E}
+	iv := []byte{0x56, 0xB6, 0x8B, 0x04, 0x19, 0xD3, 0xD8, 0x42, 0xCF, 0x1E, 0x4D, 0x70, 0x71, 0x1A, 0xA6, 0x67}
+	sm4Block, _ := sm4.NewCipher(key)
+	aesBlock, _ := aes.NewCipher(key)
+
+	for _, block := range []cipher.Block{sm4Block, aesBlock} {
+		for _, p := range []Padding{PKCS7, ISO7816, X923} {
+			plain := []byte("hello world.")
+			data := p.Pad(plain, block.BlockSize())
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+			out, err := p.Unpad(data, block.BlockSize())
+			if err != nil || !bytes.Equal(out, plain) {
+				t.Fatal("invalid cbc round trip")
+			}
+		}
+	}
+}
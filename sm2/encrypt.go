@@ -0,0 +1,183 @@
+package sm2
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/anhk/crypto/kdf"
+	"github.com/anhk/crypto/sm3"
+)
+
+/**
+ * 密文排列方式
+ * - C1C3C2: GB/T 32918.4-2016 (默认)
+ * - C1C2C3: 旧版规范
+ */
+const (
+	C1C3C2 = 0
+	C1C2C3 = 1
+)
+
+var errDecryption = errors.New("sm2: decryption error")
+
+/**
+ * GM/T 0009 密文的ASN.1结构
+ */
+type sm2Cipher struct {
+	XCoordinate *big.Int
+	YCoordinate *big.Int
+	HASH        []byte
+	CipherText  []byte
+}
+
+func isAllZero(b []byte) bool {
+	var v byte
+	for _, x := range b {
+		v |= x
+	}
+	return v == 0
+}
+
+/**
+ * C3 = H(x2 || M || y2)
+ */
+func c3Hash(x2, m, y2 []byte) []byte {
+	h := sm3.New()
+	h.Write(x2)
+	h.Write(m)
+	h.Write(y2)
+	return h.Sum(nil)
+}
+
+/**
+ * 加密 (GB/T 32918.4 6.1)
+ * - C1 = [k]G
+ * - (x2, y2) = [k]PB
+ * - t = KDF(x2 || y2, klen)，t 不能全为0
+ * - C2 = M ^ t
+ * - C3 = H(x2 || M || y2)
+ */
+func encrypt(rand io.Reader, pub *PublicKey, msg []byte) (x1, y1 *big.Int, c2, c3 []byte, err error) {
+	c := pub.Curve
+	for {
+		k, err := randScalar(c, rand)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		x1, y1 = c.ScalarBaseMult(k.Bytes())
+		x2, y2 := c.ScalarMult(pub.X, pub.Y, k.Bytes())
+		x2Buf, y2Buf := bigIntTo32Bytes(x2), bigIntTo32Bytes(y2)
+
+		t := kdf.KDF(append(append([]byte{}, x2Buf...), y2Buf...), len(msg))
+		if len(msg) > 0 && isAllZero(t) {
+			continue
+		}
+
+		c2 = make([]byte, len(msg))
+		for i := range msg {
+			c2[i] = msg[i] ^ t[i]
+		}
+		return x1, y1, c2, c3Hash(x2Buf, msg, y2Buf), nil
+	}
+}
+
+/**
+ * 解密 (GB/T 32918.4 7.1)
+ * - 验证 C1 在曲线上
+ * - (x2, y2) = [dB]C1
+ * - t = KDF(x2 || y2, klen)，t 不能全为0
+ * - M = C2 ^ t
+ * - 验证 H(x2 || M || y2) == C3
+ */
+func decrypt(priv *PrivateKey, x1, y1 *big.Int, c2, c3 []byte) ([]byte, error) {
+	c := priv.Curve
+	if !c.IsOnCurve(x1, y1) {
+		return nil, errDecryption
+	}
+	x2, y2 := c.ScalarMult(x1, y1, priv.D.Bytes())
+	x2Buf, y2Buf := bigIntTo32Bytes(x2), bigIntTo32Bytes(y2)
+
+	t := kdf.KDF(append(append([]byte{}, x2Buf...), y2Buf...), len(c2))
+	if len(c2) > 0 && isAllZero(t) {
+		return nil, errDecryption
+	}
+
+	m := make([]byte, len(c2))
+	for i := range c2 {
+		m[i] = c2[i] ^ t[i]
+	}
+	if subtle.ConstantTimeCompare(c3Hash(x2Buf, m, y2Buf), c3) != 1 {
+		return nil, errDecryption
+	}
+	return m, nil
+}
+
+//Encrypt - 公钥加密，mode 为 C1C3C2 或 C1C2C3，C1 使用未压缩格式 04 || x1 || y1
+func Encrypt(rand io.Reader, pub *PublicKey, msg []byte, mode int) ([]byte, error) {
+	if mode != C1C3C2 && mode != C1C2C3 {
+		return nil, errors.New("sm2: invalid cipher text mode")
+	}
+	x1, y1, c2, c3, err := encrypt(rand, pub, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := elliptic.Marshal(pub.Curve, x1, y1)
+	if mode == C1C3C2 {
+		out = append(out, c3...)
+		return append(out, c2...), nil
+	}
+	out = append(out, c2...)
+	return append(out, c3...), nil
+}
+
+//Decrypt - 私钥解密，mode 需与加密时一致
+func Decrypt(priv *PrivateKey, ciphertext []byte, mode int) ([]byte, error) {
+	if mode != C1C3C2 && mode != C1C2C3 {
+		return nil, errors.New("sm2: invalid cipher text mode")
+	}
+	c1Len := 1 + 2*32
+	if len(ciphertext) < c1Len+sm3.DigestLength {
+		return nil, errDecryption
+	}
+	x1, y1 := elliptic.Unmarshal(priv.Curve, ciphertext[:c1Len])
+	if x1 == nil {
+		return nil, errDecryption
+	}
+
+	var c2, c3 []byte
+	if mode == C1C3C2 {
+		c3 = ciphertext[c1Len : c1Len+sm3.DigestLength]
+		c2 = ciphertext[c1Len+sm3.DigestLength:]
+	} else {
+		c2 = ciphertext[c1Len : len(ciphertext)-sm3.DigestLength]
+		c3 = ciphertext[len(ciphertext)-sm3.DigestLength:]
+	}
+	return decrypt(priv, x1, y1, c2, c3)
+}
+
+//EncryptASN1 - 公钥加密，输出 GM/T 0009 定义的 ASN.1 DER 编码密文
+func EncryptASN1(rand io.Reader, pub *PublicKey, msg []byte) ([]byte, error) {
+	x1, y1, c2, c3, err := encrypt(rand, pub, msg)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2Cipher{x1, y1, c3, c2})
+}
+
+//DecryptASN1 - 私钥解密 GM/T 0009 定义的 ASN.1 DER 编码密文
+func DecryptASN1(priv *PrivateKey, ciphertext []byte) ([]byte, error) {
+	var c sm2Cipher
+	rest, err := asn1.Unmarshal(ciphertext, &c)
+	if err != nil || len(rest) != 0 {
+		return nil, errDecryption
+	}
+	if c.XCoordinate.Sign() < 0 || c.YCoordinate.Sign() < 0 || len(c.HASH) != sm3.DigestLength {
+		return nil, errDecryption
+	}
+	return decrypt(priv, c.XCoordinate, c.YCoordinate, c.CipherText, c.HASH)
+}
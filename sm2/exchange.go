@@ -0,0 +1,217 @@
+package sm2
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/anhk/crypto/kdf"
+	"github.com/anhk/crypto/sm3"
+)
+
+var errConfirmation = errors.New("sm2: key exchange confirmation failed")
+
+/**
+ * 密钥交换协议 (GB/T 32918.3)
+ *
+ *   发起方 A                                        响应方 B
+ *   Init()                  ------ RA ------->
+ *                           <--- RB, [SB] ----  Respond(RA)
+ *   ConfirmResponder(RB, SB) ----- [SA] ----->  ConfirmInitiator(SA)
+ *
+ * SB/SA 为可选的确认值，双方得到相同的共享密钥 K
+ */
+type KeyExchange struct {
+	initiator bool
+	keyLen    int
+
+	priv    *PrivateKey
+	peerPub *PublicKey
+	z       []byte // 本方 Z
+	peerZ   []byte // 对方 Z
+
+	ephemeral *PublicKey // 本方临时公钥 R = [r]G
+	t         *big.Int   // t = (d + x̄ · r) mod n
+	peerR     *PublicKey
+	sharedX   []byte // U/V 的 x 坐标
+	sharedY   []byte // U/V 的 y 坐标
+	key       []byte
+}
+
+//NewInitiator - 创建发起方(A)的密钥交换状态，keyLen 为协商密钥的字节长度
+func NewInitiator(priv *PrivateKey, peerPub *PublicKey, uid, peerUID []byte, keyLen int) (*KeyExchange, error) {
+	return newKeyExchange(true, priv, peerPub, uid, peerUID, keyLen)
+}
+
+//NewResponder - 创建响应方(B)的密钥交换状态，keyLen 为协商密钥的字节长度
+func NewResponder(priv *PrivateKey, peerPub *PublicKey, uid, peerUID []byte, keyLen int) (*KeyExchange, error) {
+	return newKeyExchange(false, priv, peerPub, uid, peerUID, keyLen)
+}
+
+func newKeyExchange(initiator bool, priv *PrivateKey, peerPub *PublicKey, uid, peerUID []byte, keyLen int) (*KeyExchange, error) {
+	if keyLen <= 0 {
+		return nil, errors.New("sm2: invalid key length")
+	}
+	if !peerPub.Curve.IsOnCurve(peerPub.X, peerPub.Y) {
+		return nil, errors.New("sm2: peer public key is not on curve")
+	}
+
+	z, err := ZA(&priv.PublicKey, uid)
+	if err != nil {
+		return nil, err
+	}
+	peerZ, err := ZA(peerPub, peerUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyExchange{
+		initiator: initiator,
+		keyLen:    keyLen,
+		priv:      priv,
+		peerPub:   peerPub,
+		z:         z,
+		peerZ:     peerZ,
+	}, nil
+}
+
+/**
+ * x̄ = 2^w + (x & (2^w - 1))，w = ⌈(⌈log2(n)⌉ / 2)⌉ - 1
+ */
+func (ke *KeyExchange) reduce(x *big.Int) *big.Int {
+	w := (ke.priv.Curve.Params().N.BitLen()+1)/2 - 1
+	twoW := new(big.Int).Lsh(one, uint(w))
+	mask := new(big.Int).Sub(twoW, one)
+	return new(big.Int).Add(twoW, new(big.Int).And(x, mask))
+}
+
+/**
+ * 生成临时密钥对 R = [r]G，并计算 t = (d + x̄ · r) mod n
+ */
+func (ke *KeyExchange) generateEphemeral(rand io.Reader) error {
+	c := ke.priv.Curve
+	r, err := randScalar(c, rand)
+	if err != nil {
+		return err
+	}
+	x, y := c.ScalarBaseMult(r.Bytes())
+	ke.ephemeral = &PublicKey{Curve: c, X: x, Y: y}
+
+	n := c.Params().N
+	ke.t = new(big.Int).Mul(ke.reduce(x), r)
+	ke.t.Add(ke.t, ke.priv.D)
+	ke.t.Mod(ke.t, n)
+	return nil
+}
+
+/**
+ * U(V) = [t](P + [x̄]R)，K = KDF(xU || yU || ZA || ZB, klen)
+ */
+func (ke *KeyExchange) computeShared(peerR *PublicKey) error {
+	c := ke.priv.Curve
+	if !c.IsOnCurve(peerR.X, peerR.Y) {
+		return errors.New("sm2: peer ephemeral key is not on curve")
+	}
+	ke.peerR = peerR
+
+	x, y := c.ScalarMult(peerR.X, peerR.Y, ke.reduce(peerR.X).Bytes())
+	x, y = c.Add(ke.peerPub.X, ke.peerPub.Y, x, y)
+	x, y = c.ScalarMult(x, y, ke.t.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return errors.New("sm2: shared point is at infinity")
+	}
+	ke.sharedX, ke.sharedY = bigIntTo32Bytes(x), bigIntTo32Bytes(y)
+
+	za, zb := ke.z, ke.peerZ
+	if !ke.initiator {
+		za, zb = zb, za
+	}
+	z := make([]byte, 0, 64+len(za)+len(zb))
+	z = append(z, ke.sharedX...)
+	z = append(z, ke.sharedY...)
+	z = append(z, za...)
+	z = append(z, zb...)
+	ke.key = kdf.KDF(z, ke.keyLen)
+	return nil
+}
+
+/**
+ * S = H(prefix || yU || H(xU || ZA || ZB || x1 || y1 || x2 || y2))
+ * SB/S1 使用 prefix 0x02，SA/S2 使用 prefix 0x03
+ */
+func (ke *KeyExchange) confirmation(prefix byte) []byte {
+	ra, rb := ke.ephemeral, ke.peerR
+	za, zb := ke.z, ke.peerZ
+	if !ke.initiator {
+		ra, rb = rb, ra
+		za, zb = zb, za
+	}
+
+	h := sm3.New()
+	h.Write(ke.sharedX)
+	h.Write(za)
+	h.Write(zb)
+	h.Write(bigIntTo32Bytes(ra.X))
+	h.Write(bigIntTo32Bytes(ra.Y))
+	h.Write(bigIntTo32Bytes(rb.X))
+	h.Write(bigIntTo32Bytes(rb.Y))
+	inner := h.Sum(nil)
+
+	h.Reset()
+	h.Write([]byte{prefix})
+	h.Write(ke.sharedY)
+	h.Write(inner)
+	return h.Sum(nil)
+}
+
+//Init - 发起方生成临时公钥 RA，发送给响应方
+func (ke *KeyExchange) Init(rand io.Reader) (*PublicKey, error) {
+	if !ke.initiator {
+		return nil, errors.New("sm2: Init called on responder")
+	}
+	if err := ke.generateEphemeral(rand); err != nil {
+		return nil, err
+	}
+	return ke.ephemeral, nil
+}
+
+//Respond - 响应方收到 RA，返回临时公钥 RB 及确认值 SB
+func (ke *KeyExchange) Respond(rand io.Reader, rA *PublicKey) (rB *PublicKey, sB []byte, err error) {
+	if ke.initiator {
+		return nil, nil, errors.New("sm2: Respond called on initiator")
+	}
+	if err := ke.generateEphemeral(rand); err != nil {
+		return nil, nil, err
+	}
+	if err := ke.computeShared(rA); err != nil {
+		return nil, nil, err
+	}
+	return ke.ephemeral, ke.confirmation(0x02), nil
+}
+
+//ConfirmResponder - 发起方收到 RB 和 SB，返回共享密钥及发送给响应方的确认值 SA
+// sB 为 nil 时跳过对响应方的确认
+func (ke *KeyExchange) ConfirmResponder(rB *PublicKey, sB []byte) (key, sA []byte, err error) {
+	if !ke.initiator || ke.ephemeral == nil {
+		return nil, nil, errors.New("sm2: ConfirmResponder called out of order")
+	}
+	if err := ke.computeShared(rB); err != nil {
+		return nil, nil, err
+	}
+	if sB != nil && subtle.ConstantTimeCompare(ke.confirmation(0x02), sB) != 1 {
+		return nil, nil, errConfirmation
+	}
+	return ke.key, ke.confirmation(0x03), nil
+}
+
+//ConfirmInitiator - 响应方收到 SA 后返回共享密钥，sA 为 nil 时跳过对发起方的确认
+func (ke *KeyExchange) ConfirmInitiator(sA []byte) ([]byte, error) {
+	if ke.initiator || ke.key == nil {
+		return nil, errors.New("sm2: ConfirmInitiator called out of order")
+	}
+	if sA != nil && subtle.ConstantTimeCompare(ke.confirmation(0x03), sA) != 1 {
+		return nil, errConfirmation
+	}
+	return ke.key, nil
+}
@@ -0,0 +1,230 @@
+package sm2
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/anhk/crypto/sm3"
+)
+
+//DefaultUID - 未指定用户身份标识时使用的默认ID
+var DefaultUID = []byte("1234567812345678")
+
+var one = big.NewInt(1)
+
+//PublicKey -
+type PublicKey struct {
+	elliptic.Curve
+	X, Y *big.Int
+}
+
+//PrivateKey -
+type PrivateKey struct {
+	PublicKey
+	D *big.Int
+}
+
+//SignerOpts - 签名时携带的用户身份标识，UID为空时使用DefaultUID
+type SignerOpts struct {
+	UID []byte
+}
+
+//HashFunc - SM2 签名对原始消息进行 ZA || M 的杂凑，不接受预先计算的摘要
+func (opts *SignerOpts) HashFunc() crypto.Hash {
+	return crypto.Hash(0)
+}
+
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+/**
+ * 生成 [1, n-2] 范围内的随机数
+ * 私钥 d 需满足 1+d 在模 n 下可逆，签名随机数 k 使用同样的范围
+ */
+func randScalar(c elliptic.Curve, rand io.Reader) (*big.Int, error) {
+	params := c.Params()
+	b := make([]byte, params.BitSize/8+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+
+	k := new(big.Int).SetBytes(b)
+	n := new(big.Int).Sub(params.N, big.NewInt(2))
+	k.Mod(k, n)
+	k.Add(k, one)
+	return k, nil
+}
+
+//GenerateKey - 在 SM2 推荐曲线上生成密钥对
+func GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	c := P256Sm2()
+	d, err := randScalar(c, rand)
+	if err != nil {
+		return nil, err
+	}
+	priv := new(PrivateKey)
+	priv.PublicKey.Curve = c
+	priv.D = d
+	priv.PublicKey.X, priv.PublicKey.Y = c.ScalarBaseMult(d.Bytes())
+	return priv, nil
+}
+
+//Public - implements crypto.Signer
+func (priv *PrivateKey) Public() crypto.PublicKey {
+	return &priv.PublicKey
+}
+
+//Sign - implements crypto.Signer, returns an ASN.1 DER encoded (r, s).
+// msg is the raw message, not a digest: the ZA || M hash is computed
+// internally. Pass *SignerOpts to sign with a UID other than DefaultUID.
+func (priv *PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	uid := DefaultUID
+	if o, ok := opts.(*SignerOpts); ok && len(o.UID) > 0 {
+		uid = o.UID
+	} else if opts != nil && opts.HashFunc() != 0 {
+		return nil, errors.New("sm2: cannot sign a pre-hashed message")
+	}
+
+	r, s, err := Sign(rand, priv, uid, msg)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2Signature{r, s})
+}
+
+//Verify - verifies an ASN.1 DER encoded signature of msg with DefaultUID.
+func (pub *PublicKey) Verify(msg, sig []byte) bool {
+	var s sm2Signature
+	rest, err := asn1.Unmarshal(sig, &s)
+	if err != nil || len(rest) != 0 {
+		return false
+	}
+	return Verify(pub, DefaultUID, msg, s.R, s.S)
+}
+
+func bigIntTo32Bytes(n *big.Int) []byte {
+	var out [32]byte
+	b := n.Bytes()
+	copy(out[32-len(b):], b)
+	return out[:]
+}
+
+/**
+ * ZA = H256(ENTLA || IDA || a || b || xG || yG || xA || yA)
+ * ENTLA 为 IDA 的比特长度，两个字节
+ */
+func ZA(pub *PublicKey, uid []byte) ([]byte, error) {
+	bitLen := len(uid) * 8
+	if bitLen > 0xFFFF {
+		return nil, errors.New("sm2: uid too long")
+	}
+	params := pub.Curve.Params()
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+
+	h := sm3.New()
+	h.Write([]byte{byte(bitLen >> 8), byte(bitLen)})
+	h.Write(uid)
+	h.Write(bigIntTo32Bytes(a))
+	h.Write(bigIntTo32Bytes(params.B))
+	h.Write(bigIntTo32Bytes(params.Gx))
+	h.Write(bigIntTo32Bytes(params.Gy))
+	h.Write(bigIntTo32Bytes(pub.X))
+	h.Write(bigIntTo32Bytes(pub.Y))
+	return h.Sum(nil), nil
+}
+
+/**
+ * e = H256(ZA || M)
+ */
+func hashMsg(pub *PublicKey, uid, msg []byte) (*big.Int, error) {
+	za, err := ZA(pub, uid)
+	if err != nil {
+		return nil, err
+	}
+	h := sm3.New()
+	h.Write(za)
+	h.Write(msg)
+	return new(big.Int).SetBytes(h.Sum(nil)), nil
+}
+
+/**
+ * 签名 (GB/T 32918.2 6.1)
+ * - (x1, y1) = [k]G
+ * - r = (e + x1) mod n, r != 0 且 r + k != n
+ * - s = ((1 + d)^-1 * (k - r*d)) mod n, s != 0
+ */
+func Sign(rand io.Reader, priv *PrivateKey, uid, msg []byte) (r, s *big.Int, err error) {
+	e, err := hashMsg(&priv.PublicKey, uid, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := priv.PublicKey.Curve
+	n := c.Params().N
+	dInv := new(big.Int).Add(priv.D, one)
+	dInv.ModInverse(dInv, n)
+
+	for {
+		k, err := randScalar(c, rand)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		x1, _ := c.ScalarBaseMult(k.Bytes())
+		r = new(big.Int).Add(e, x1)
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			continue
+		}
+
+		s = new(big.Int).Mul(r, priv.D)
+		s.Sub(k, s)
+		s.Mul(s, dInv)
+		s.Mod(s, n)
+		if s.Sign() != 0 {
+			return r, s, nil
+		}
+	}
+}
+
+/**
+ * 验签 (GB/T 32918.2 7.1)
+ * - r, s ∈ [1, n-1]
+ * - t = (r + s) mod n, t != 0
+ * - (x1, y1) = [s]G + [t]PA
+ * - R = (e + x1) mod n, R == r
+ */
+func Verify(pub *PublicKey, uid, msg []byte, r, s *big.Int) bool {
+	c := pub.Curve
+	n := c.Params().N
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	if !c.IsOnCurve(pub.X, pub.Y) {
+		return false
+	}
+
+	e, err := hashMsg(pub, uid, msg)
+	if err != nil {
+		return false
+	}
+
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+
+	x1, y1 := c.ScalarBaseMult(s.Bytes())
+	x2, y2 := c.ScalarMult(pub.X, pub.Y, t.Bytes())
+	x1, _ = c.Add(x1, y1, x2, y2)
+
+	x1.Add(x1, e)
+	x1.Mod(x1, n)
+	return x1.Cmp(r) == 0
+}
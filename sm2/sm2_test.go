@@ -0,0 +1,382 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/anhk/crypto/sm4"
+)
+
+func TestP256Sm2(t *testing.T) {
+	c := P256Sm2()
+	params := c.Params()
+	if !c.IsOnCurve(params.Gx, params.Gy) {
+		t.Fatal("G is not on curve")
+	}
+	x, y := c.ScalarBaseMult(params.N.Bytes())
+	if x.Sign() != 0 || y.Sign() != 0 {
+		t.Fatal("[n]G is not the point at infinity")
+	}
+}
+
+/**
+ * GM/T 0003.5 示例密钥对
+ */
+func TestGenerateKey(t *testing.T) {
+	d, _ := new(big.Int).SetString("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8", 16)
+	x, y := P256Sm2().ScalarBaseMult(d.Bytes())
+	if hex.EncodeToString(x.Bytes()) != "09f9df311e5421a150dd7d161e4bc5c672179fad1833fc076bb08ff356f35020" ||
+		hex.EncodeToString(y.Bytes()) != "ccea490ce26775a52dc6ea718cc1aa600aed05fbf35e084a6632f6072da9ad13" {
+		t.Fatal("invalid public key")
+	}
+
+	priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.Curve.IsOnCurve(priv.X, priv.Y) {
+		t.Fatal("public key is not on curve")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	msg := []byte("message digest")
+	uid := []byte("ALICE123@YAHOO.COM")
+
+	r, s, err := Sign(rand.Reader, priv, uid, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(&priv.PublicKey, uid, msg, r, s) {
+		t.Fatal("verify failed")
+	}
+	if Verify(&priv.PublicKey, DefaultUID, msg, r, s) {
+		t.Fatal("verify succeeded with a different uid")
+	}
+	if Verify(&priv.PublicKey, uid, []byte("message digesT"), r, s) {
+		t.Fatal("verify succeeded with a different message")
+	}
+	if Verify(&priv.PublicKey, uid, msg, r, new(big.Int).Add(s, one)) {
+		t.Fatal("verify succeeded with a modified signature")
+	}
+}
+
+func fixedKey(d string) *PrivateKey {
+	priv := &PrivateKey{}
+	priv.D, _ = new(big.Int).SetString(d, 16)
+	priv.Curve = P256Sm2()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(priv.D.Bytes())
+	return priv
+}
+
+/**
+ * randScalar 返回 b mod (n-2) + 1，输入 k-1 得到指定的随机数 k
+ */
+func fixedScalar(k string) *bytes.Reader {
+	v, _ := new(big.Int).SetString(k, 16)
+	b := make([]byte, 40)
+	km1 := new(big.Int).Sub(v, one).Bytes()
+	copy(b[40-len(km1):], km1)
+	return bytes.NewReader(b)
+}
+
+/**
+ * GM/T 0003.5 (GB/T 32918.5) 附录A 推荐曲线上的签名示例
+ * M = "message digest"，ID 为默认的 "1234567812345678"，固定随机数 k
+ */
+func TestSignVector(t *testing.T) {
+	priv := fixedKey("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8")
+	k := "59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21"
+	msg := []byte("message digest")
+
+	za, _ := ZA(&priv.PublicKey, DefaultUID)
+	if hex.EncodeToString(za) != "b2e14c5c79c6df5b85f4fe7ed8db7a262b9da7e07ccb0ea9f4747b8ccda8a4f3" {
+		t.Fatalf("invalid ZA %x", za)
+	}
+	e, _ := hashMsg(&priv.PublicKey, DefaultUID, msg)
+	if hex.EncodeToString(e.Bytes()) != "f0b43e94ba45accaace692ed534382eb17e6ab5a19ce7b31f4486fdfc0d28640" {
+		t.Fatalf("invalid e %x", e)
+	}
+
+	r, s, err := Sign(fixedScalar(k), priv, DefaultUID, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(r.Bytes()) != "f5a03b0648d2c4630eeac513e1bb81a15944da3827d5b74143ac7eaceee720b3" ||
+		hex.EncodeToString(s.Bytes()) != "b1b6aa29df212fd8763182bc0d421ca1bb9038fd1f7f42d4840b69c485bbc1aa" {
+		t.Fatalf("invalid signature %x %x", r, s)
+	}
+	if !Verify(&priv.PublicKey, DefaultUID, msg, r, s) {
+		t.Fatal("verify failed")
+	}
+
+	/**
+	 * crypto.Signer 未指定 UID 时使用默认ID，结果相同
+	 */
+	sig, err := priv.Sign(fixedScalar(k), msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := asn1.Marshal(sm2Signature{r, s})
+	if !bytes.Equal(sig, expected) {
+		t.Fatal("Signer with default UID differs from the example")
+	}
+}
+
+func TestSigner(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	var signer crypto.Signer = priv
+	msg := []byte("hello world.")
+
+	sig, err := signer.Sign(rand.Reader, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := signer.Public().(*PublicKey)
+	if !pub.Verify(msg, sig) {
+		t.Fatal("verify failed")
+	}
+
+	sig, err = signer.Sign(rand.Reader, msg, &SignerOpts{UID: []byte("bob")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Verify(msg, sig) {
+		t.Fatal("verify succeeded with a different uid")
+	}
+
+	if _, err := signer.Sign(rand.Reader, msg, crypto.SHA256); err == nil {
+		t.Fatal("signing a pre-hashed message should fail")
+	}
+}
+
+/**
+ * GM/T 0003.5 加密示例，固定随机数 k
+ */
+func TestEncryptVector(t *testing.T) {
+	d, _ := new(big.Int).SetString("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8", 16)
+	k, _ := new(big.Int).SetString("59276E27D506861A16680F3AD9C02DCCEF3CC1FA3CDBE4CE6D54B80DEAC1BC21", 16)
+	priv := &PrivateKey{D: d}
+	priv.Curve = P256Sm2()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d.Bytes())
+
+	// randScalar 返回 (r mod (n-2)) + 1
+	r := append(make([]byte, 8), bigIntTo32Bytes(k.Sub(k, one))...)
+
+	ct, err := Encrypt(bytes.NewReader(r), &priv.PublicKey, []byte("encryption standard"), C1C3C2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "04" +
+		"04ebfc718e8d1798620432268e77feb6415e2ede0e073c0f4f640ecd2e149a73" +
+		"e858f9d81e5430a57b36daab8f950a3c64e6ee6a63094d99283aff767e124df0" +
+		"59983c18f809e262923c53aec295d30383b54e39d609d160afcb1908d0bd8766" +
+		"21886ca989ca9c7d58087307ca93092d651efa"
+	if hex.EncodeToString(ct) != expected {
+		t.Fatal("invalid encrypt")
+	}
+
+	m, err := Decrypt(priv, ct, C1C3C2)
+	if err != nil || string(m) != "encryption standard" {
+		t.Fatal("invalid decrypt")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	msg := []byte("1234567890123456123456789012345612345678901234561234567890123456")
+
+	for _, mode := range []int{C1C3C2, C1C2C3} {
+		ct, err := Encrypt(rand.Reader, &priv.PublicKey, msg, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ct) != 65+32+len(msg) {
+			t.Fatal("invalid cipher text length")
+		}
+		m, err := Decrypt(priv, ct, mode)
+		if err != nil || !bytes.Equal(m, msg) {
+			t.Fatal("invalid decrypt")
+		}
+
+		ct[len(ct)-1] ^= 1
+		if _, err := Decrypt(priv, ct, mode); err == nil {
+			t.Fatal("decrypt succeeded with a modified cipher text")
+		}
+	}
+
+	ct, _ := Encrypt(rand.Reader, &priv.PublicKey, msg, C1C3C2)
+	if _, err := Decrypt(priv, ct, C1C2C3); err == nil {
+		t.Fatal("decrypt succeeded with the wrong mode")
+	}
+}
+
+func TestEncryptASN1(t *testing.T) {
+	priv, _ := GenerateKey(rand.Reader)
+	msg := []byte("hello world.")
+
+	ct, err := EncryptASN1(rand.Reader, &priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := DecryptASN1(priv, ct)
+	if err != nil || !bytes.Equal(m, msg) {
+		t.Fatal("invalid decrypt")
+	}
+
+	if _, err := DecryptASN1(priv, ct[:len(ct)-1]); err == nil {
+		t.Fatal("decrypt succeeded with a truncated cipher text")
+	}
+}
+
+func TestKeyExchange(t *testing.T) {
+	privA, _ := GenerateKey(rand.Reader)
+	privB, _ := GenerateKey(rand.Reader)
+	uidA, uidB := []byte("Alice"), []byte("Bob")
+
+	for _, keyLen := range []int{16, 48} {
+		initiator, err := NewInitiator(privA, &privB.PublicKey, uidA, uidB, keyLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		responder, err := NewResponder(privB, &privA.PublicKey, uidB, uidA, keyLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rA, err := initiator.Init(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rB, sB, err := responder.Respond(rand.Reader, rA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyA, sA, err := initiator.ConfirmResponder(rB, sB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyB, err := responder.ConfirmInitiator(sA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keyA) != keyLen || !bytes.Equal(keyA, keyB) {
+			t.Fatal("shared keys mismatch")
+		}
+
+		if _, err := responder.ConfirmInitiator(sB); err != errConfirmation {
+			t.Fatal("confirmation succeeded with a wrong value")
+		}
+	}
+}
+
+/**
+ * GM/T 0003.5 (GB/T 32918.5) 推荐曲线上的密钥交换示例
+ * IDA = IDB = "1234567812345678"，klen = 128 比特，固定临时私钥 rA/rB
+ * GB/T 32918.3 附录中的示例使用 a != -3 的测试曲线，无法用 elliptic.CurveParams 表示
+ * S1 = SB、S2 = SA，杂凑顺序为 xV || ZA || ZB || x1 || y1 || x2 || y2
+ */
+func TestKeyExchangeVector(t *testing.T) {
+	privA := fixedKey("81EB26E941BB5AF16DF116495F90695272AE2CD63D6C4AE1678418BE48230029")
+	privB := fixedKey("785129917D45A9EA5437A59356B82338EAADDA6CEB199088F14AE10DEFA229B5")
+
+	initiator, _ := NewInitiator(privA, &privB.PublicKey, DefaultUID, DefaultUID, 16)
+	responder, _ := NewResponder(privB, &privA.PublicKey, DefaultUID, DefaultUID, 16)
+	if hex.EncodeToString(initiator.z) != "3b85a57179e11e7e513aa622991f2ca74d1807a0bd4d4b38f90987a17ac245b1" ||
+		hex.EncodeToString(responder.z) != "79c988d63229d97ef19fe02ca1056e01e6a7411ed24694aa8f834f4a4ab022f7" {
+		t.Fatal("invalid ZA/ZB")
+	}
+
+	rA, err := initiator.Init(fixedScalar("D4DE15474DB74D06491C440D305E012400990F3E390C7E87153C12DB2EA60BB3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(bigIntTo32Bytes(rA.X)) != "64ced1bdbc99d590049b434d0fd73428cf608a5db8fe5ce07f15026940bae40e" ||
+		hex.EncodeToString(bigIntTo32Bytes(rA.Y)) != "376629c7ab21e7db260922499ddb118f07ce8eaae3e7720afef6a5cc062070c0" {
+		t.Fatal("invalid RA")
+	}
+
+	rB, sB, err := responder.Respond(fixedScalar("7E07124814B309489125EAED101113164EBF0F3458C5BD88335C1F9D596243D6"), rA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(bigIntTo32Bytes(rB.X)) != "acc27688a6f7b706098bc91ff3ad1bff7dc2802cdb14ccccdb0a90471f9bd707" ||
+		hex.EncodeToString(bigIntTo32Bytes(rB.Y)) != "2fedac0494b2ffc4d6853876c79b8f301c6573ad0aa50f39fc87181e1a1b46fe" {
+		t.Fatal("invalid RB")
+	}
+	if hex.EncodeToString(responder.sharedX) != "c558b44bee5301d9f52b44d939bb59584d75b9034dd6a9fc826872109a65739f" ||
+		hex.EncodeToString(responder.sharedY) != "3252b35b191d8ae01cd122c025204334c5eacf68a0cb4854c6a7d367ecad4de7" {
+		t.Fatal("invalid V")
+	}
+	if hex.EncodeToString(sB) != "d3a0fe15dee185ceae907a6b595cc32a266ed7b3367e9983a896dc32fa20f8eb" {
+		t.Fatalf("invalid SB %x", sB)
+	}
+
+	keyA, sA, err := initiator.ConfirmResponder(rB, sB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initiator.sharedX, responder.sharedX) || !bytes.Equal(initiator.sharedY, responder.sharedY) {
+		t.Fatal("U != V")
+	}
+	if s1 := initiator.confirmation(0x02); !bytes.Equal(s1, sB) {
+		t.Fatalf("invalid S1 %x", s1)
+	}
+	if hex.EncodeToString(sA) != "18c7894b3816df16cf07b05c5ec0bef5d655d58f779cc1b400a4f3884644db88" {
+		t.Fatalf("invalid SA %x", sA)
+	}
+	if s2 := responder.confirmation(0x03); !bytes.Equal(s2, sA) {
+		t.Fatalf("invalid S2 %x", s2)
+	}
+
+	keyB, err := responder.ConfirmInitiator(sA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(keyA) != "6c89347354de2484c60b4ab1fde4c6e5" || !bytes.Equal(keyA, keyB) {
+		t.Fatalf("invalid shared key %x %x", keyA, keyB)
+	}
+}
+
+func TestKeyExchangeSM4(t *testing.T) {
+	privA, _ := GenerateKey(rand.Reader)
+	privB, _ := GenerateKey(rand.Reader)
+
+	initiator, _ := NewInitiator(privA, &privB.PublicKey, DefaultUID, DefaultUID, sm4.KeySize)
+	responder, _ := NewResponder(privB, &privA.PublicKey, DefaultUID, DefaultUID, sm4.KeySize)
+	rA, _ := initiator.Init(rand.Reader)
+	rB, sB, _ := responder.Respond(rand.Reader, rA)
+
+	sB[0] ^= 1
+	if _, _, err := initiator.ConfirmResponder(rB, sB); err != errConfirmation {
+		t.Fatal("confirmation succeeded with a wrong value")
+	}
+
+	// 不进行确认
+	keyA, _, err := initiator.ConfirmResponder(rB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := responder.ConfirmInitiator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockA, err := sm4.NewCipher(keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockB, _ := sm4.NewCipher(keyB)
+	data := []byte("1234567890123456")
+	blockA.Encrypt(data, data)
+	blockB.Decrypt(data, data)
+	if string(data) != "1234567890123456" {
+		t.Fatal("shared keys mismatch")
+	}
+}
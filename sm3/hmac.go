@@ -0,0 +1,11 @@
+package sm3
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+//NewHMAC - 创建 HMAC-SM3 (GB/T 15852.2, RFC 2104)
+func NewHMAC(key []byte) hash.Hash {
+	return hmac.New(New, key)
+}
@@ -0,0 +1,101 @@
+package sm3
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+/**
+ * 序列化格式:
+ * magic("sm3") || version(1) || v[8] || inWords[16] || xOff || xBuf[4] || xBufOff || byteCount
+ * 整数均为大端序，w 为每个分组的临时数据，不需要保存
+ */
+const (
+	magic          = "sm3"
+	marshalVersion = 1
+	marshaledSize  = len(magic) + 1 + 8*4 + blockWords*4 + 4 + 4 + 4 + 8
+)
+
+//MarshalBinary - 保存当前的中间状态，可在其他进程中通过 UnmarshalBinary 恢复后继续计算
+func (sm3 *SM3) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, marshaledSize)
+	b = append(b, magic...)
+	b = append(b, marshalVersion)
+	for _, x := range sm3.v {
+		b = appendUint32(b, x)
+	}
+	for _, x := range sm3.inWords {
+		b = appendUint32(b, x)
+	}
+	b = appendUint32(b, uint32(sm3.xOff))
+	b = append(b, sm3.xBuf[:]...)
+	b = appendUint32(b, uint32(sm3.xBufOff))
+	b = appendUint64(b, uint64(sm3.byteCount))
+	return b, nil
+}
+
+//UnmarshalBinary - 恢复 MarshalBinary 保存的状态
+func (sm3 *SM3) UnmarshalBinary(b []byte) error {
+	if len(b) < len(magic)+1 || string(b[:len(magic)]) != magic {
+		return errors.New("sm3: invalid hash state identifier")
+	}
+	if b[len(magic)] != marshalVersion {
+		return errors.New("sm3: unsupported hash state version")
+	}
+	if len(b) != marshaledSize {
+		return errors.New("sm3: invalid hash state size")
+	}
+
+	b = b[len(magic)+1:]
+	var v [8]uint32
+	var inWords [blockWords]uint32
+	for i := range v {
+		b, v[i] = consumeUint32(b)
+	}
+	for i := range inWords {
+		b, inWords[i] = consumeUint32(b)
+	}
+	b, xOff := consumeUint32(b)
+	var xBuf [4]byte
+	copy(xBuf[:], b)
+	b = b[len(xBuf):]
+	b, xBufOff := consumeUint32(b)
+	_, byteCount := consumeUint64(b)
+
+	/**
+	 * 缓冲的字数、字节数必须与总长度一致
+	 */
+	if xOff >= blockWords || xBufOff >= 4 || byteCount > 1<<61 ||
+		byteCount%BlockSize != uint64(xOff)*4+uint64(xBufOff) {
+		return errors.New("sm3: invalid hash state")
+	}
+
+	sm3.Reset()
+	sm3.v = v
+	sm3.inWords = inWords
+	sm3.xOff = int32(xOff)
+	sm3.xBuf = xBuf
+	sm3.xBufOff = int32(xBufOff)
+	sm3.byteCount = int64(byteCount)
+	return nil
+}
+
+func appendUint32(b []byte, x uint32) []byte {
+	var a [4]byte
+	binary.BigEndian.PutUint32(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.BigEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
+}
+
+func consumeUint32(b []byte) ([]byte, uint32) {
+	return b[4:], binary.BigEndian.Uint32(b)
+}
+
+func consumeUint64(b []byte) ([]byte, uint64) {
+	return b[8:], binary.BigEndian.Uint64(b)
+}
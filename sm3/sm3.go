@@ -8,7 +8,12 @@ import (
 
 const (
 	DigestLength = 32
-	BlockSize    = 16
+	BlockSize    = 64
+
+	/**
+	 * 每个分组的字数
+	 */
+	blockWords = BlockSize / 4
 )
 
 var gT = []uint32{
@@ -23,7 +28,7 @@ var gT = []uint32{
 
 type SM3 struct {
 	v         [DigestLength / 4]uint32
-	inWords   [BlockSize]uint32
+	inWords   [blockWords]uint32
 	xOff      int32
 	w         [68]uint32
 	xBuf      [4]byte
@@ -37,8 +42,9 @@ func New() hash.Hash {
 	return sm3
 }
 
+//Sum - 在状态的副本上计算摘要，不影响后续的 Write
 func (sm3 *SM3) Sum(b []byte) []byte {
-	d1 := sm3
+	d1 := *sm3
 	h := d1.checkSum()
 	return append(b, h[:]...)
 }
@@ -144,7 +150,7 @@ func (sm3 *SM3) checkSum() [DigestLength]byte {
 }
 
 func (sm3 *SM3) processBlock() {
-	for j := 0; j < 16; j++ {
+	for j := 0; j < blockWords; j++ {
 		sm3.w[j] = sm3.inWords[j]
 	}
 	for j := 16; j < 68; j++ {
@@ -220,20 +226,20 @@ func (sm3 *SM3) processWord(in []byte, inOff int32) {
 	sm3.inWords[sm3.xOff] = n
 	sm3.xOff++
 
-	if sm3.xOff >= 16 {
+	if sm3.xOff >= blockWords {
 		sm3.processBlock()
 	}
 }
 
 func (sm3 *SM3) processLength(bitLength int64) {
-	if sm3.xOff > (BlockSize - 2) {
+	if sm3.xOff > (blockWords - 2) {
 		sm3.inWords[sm3.xOff] = 0
 		sm3.xOff++
 
 		sm3.processBlock()
 	}
 
-	for ; sm3.xOff < (BlockSize - 2); sm3.xOff++ {
+	for ; sm3.xOff < (blockWords - 2); sm3.xOff++ {
 		sm3.inWords[sm3.xOff] = 0
 	}
 
@@ -1,7 +1,9 @@
 package sm3
 
 import (
+	"encoding"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"testing"
@@ -17,7 +19,7 @@ func TestNew(t *testing.T) {
 
 func TestSM3_BlockSize(t *testing.T) {
 	sm3 := New()
-	if sm3.BlockSize() != 16 {
+	if sm3.BlockSize() != 64 {
 		t.Fatal("invalid blocksize")
 	}
 	if sm3.Size() != 32 {
@@ -55,3 +57,141 @@ func TestSm3Sum3(t *testing.T) {
 	idx := Sm3Sum(data)
 	fmt.Println(base64.StdEncoding.EncodeToString(idx[:]))
 }
+
+/**
+ * 参照 RFC 4231 的测试输入，期望值由 OpenSSL 3.0.17 计算:
+ *   printf '<data hex>' | xxd -r -p | openssl mac -digest SM3 -macopt hexkey:<key hex> HMAC
+ * 例如第一组:
+ *   printf 'Hi There' | openssl mac -digest SM3 -macopt hexkey:0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b HMAC
+ */
+func TestNewHMAC(t *testing.T) {
+	repeat := func(b byte, n int) []byte {
+		return []byte(strings.Repeat(string([]byte{b}), n))
+	}
+	tests := []struct {
+		key, data []byte
+		mac       string
+	}{
+		{repeat(0x0b, 20), []byte("Hi There"),
+			"51b00d1fb49832bfb01c3ce27848e59f871d9ba938dc563b338ca964755cce70"},
+		{[]byte("Jefe"), []byte("what do ya want for nothing?"),
+			"2e87f1d16862e6d964b50a5200bf2b10b764faa9680a296a2405f24bec39f882"},
+		{repeat(0xaa, 20), repeat(0xdd, 50),
+			"dd9421e1c725bdf52ec1aa34edadb3c97f5951a83a2fa93f73a7902bc1dcc777"},
+		{[]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25}, repeat(0xcd, 50),
+			"b57c79be03472aeb8cada581dea332cb2ba83d19cb1b052dd07194def75fb8cd"},
+		{repeat(0xaa, 131), []byte("Test Using Larger Than Block-Size Key - Hash Key First"),
+			"b4fd844e13342002f0b2e0690ea7741f1497d993a70494cea601e657bedf67a0"},
+		{repeat(0xaa, 64), []byte("Hi There"),
+			"4c1b88e3886797169cb9061e48afb2cf9bb4697a906884b66212ce1372644baa"},
+		{repeat(0xaa, 65), []byte("Hi There"),
+			"151f487901fdcdf79c218fbd3226abbc00c23aff29678a80a120ab4a18ea158a"},
+	}
+
+	for i, test := range tests {
+		h := NewHMAC(test.key)
+		h.Write(test.data)
+		if mac := hex.EncodeToString(h.Sum(nil)); mac != test.mac {
+			t.Fatal("case", i, "got", mac, "want", test.mac)
+		}
+		if h.Size() != DigestLength || h.BlockSize() != BlockSize {
+			t.Fatal("invalid hmac size")
+		}
+
+		h.Reset()
+		h.Write(test.data)
+		if mac := hex.EncodeToString(h.Sum(nil)); mac != test.mac {
+			t.Fatal("case", i, "after reset got", mac)
+		}
+	}
+}
+
+func TestSumNonDestructive(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefghijklmnopqrstuvwxyz0123456789", 10))
+
+	/**
+	 * 在任意位置调用 Sum 后继续写入，结果应与一次性计算一致
+	 */
+	for _, split := range []int{0, 1, 3, 4, 55, 56, 63, 64, 65, 100, len(data)} {
+		h := New()
+		h.Write(data[:split])
+		prefix := Sm3Sum(data[:split])
+		if s := h.Sum(nil); string(s) != string(prefix[:]) {
+			t.Fatal("split", split, "prefix sum mismatch")
+		}
+		if s := h.Sum(nil); string(s) != string(prefix[:]) {
+			t.Fatal("split", split, "repeated sum mismatch")
+		}
+
+		h.Write(data[split:])
+		full := Sm3Sum(data)
+		if s := h.Sum([]byte("prefix")); string(s) != "prefix"+string(full[:]) {
+			t.Fatal("split", split, "sum after write mismatch")
+		}
+	}
+
+	/**
+	 * 逐字节写入并在每次写入后调用 Sum
+	 */
+	h := New()
+	for i := range data {
+		h.Write(data[i : i+1])
+		expected := Sm3Sum(data[:i+1])
+		if s := h.Sum(nil); string(s) != string(expected[:]) {
+			t.Fatal("byte", i, "sum mismatch")
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefghijklmnopqrstuvwxyz0123456789", 10))
+	expected := Sm3Sum(data)
+
+	for _, split := range []int{0, 1, 3, 4, 63, 64, 65, 130, len(data)} {
+		h := New()
+		h.Write(data[:split])
+		state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state) != marshaledSize || string(state[:4]) != "sm3\x01" {
+			t.Fatalf("split %d: unexpected state %x", split, state[:4])
+		}
+
+		resumed := New()
+		resumed.Write([]byte("garbage"))
+		if err := resumed.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		resumed.Write(data[split:])
+		if s := resumed.Sum(nil); string(s) != string(expected[:]) {
+			t.Fatal("split", split, "resumed sum mismatch")
+		}
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	h := New()
+	h.Write([]byte("hello"))
+	state, _ := h.(encoding.BinaryMarshaler).MarshalBinary()
+
+	modify := func(i int, b byte) []byte {
+		bad := append([]byte{}, state...)
+		bad[i] = b
+		return bad
+	}
+
+	for i, bad := range [][]byte{
+		nil,
+		state[:3],
+		state[:len(state)-1],
+		append(append([]byte{}, state...), 0),
+		modify(0, 'x'),
+		modify(3, 2),
+		modify(len(state)-1, 6),
+	} {
+		if err := new(SM3).UnmarshalBinary(bad); err == nil {
+			t.Fatal("case", i, "invalid state accepted")
+		}
+	}
+}
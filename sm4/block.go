@@ -49,8 +49,8 @@ func lAp(b uint32) uint32 {
 	return b ^ bits.RotateLeft32(b, 13) ^ bits.RotateLeft32(b, 23)
 }
 
-func tAp(b uint32) uint32 {
-	return lAp(tau(b))
+func (sm4 *SM4) tAp(b uint32) uint32 {
+	return lAp(sm4.tau(b))
 }
 
 func l(b uint32) uint32 {
@@ -58,6 +58,48 @@ func l(b uint32) uint32 {
 		bits.RotateLeft32(b, 18) ^ bits.RotateLeft32(b, 24)
 }
 
-func t(b uint32) uint32 {
-	return l(tau(b))
+/**
+ * 合成置换 T 的查表实现: L 为线性变换，因此
+ * T(a0 || a1 || a2 || a3) = L(S(a0) << 24) ^ L(S(a1) << 16) ^ L(S(a2) << 8) ^ L(S(a3))
+ */
+var tTable [4][256]uint32
+
+func init() {
+	for i := 0; i < 256; i++ {
+		s := uint32(SBox[i])
+		tTable[0][i] = l(s << 24)
+		tTable[1][i] = l(s << 16)
+		tTable[2][i] = l(s << 8)
+		tTable[3][i] = l(s)
+	}
+}
+
+func tLookup(b uint32) uint32 {
+	return tTable[0][b>>24] ^ tTable[1][b>>16&0xff] ^ tTable[2][b>>8&0xff] ^ tTable[3][b&0xff]
+}
+
+/**
+ * 32轮迭代，每4轮展开一次，状态和轮密钥保存在局部变量中
+ * X(i+4) = X(i) ^ T(X(i+1) ^ X(i+2) ^ X(i+3) ^ rk(i))
+ * 输出为反序 (X35, X34, X33, X32)
+ */
+func cryptBlock(rk *[32]uint32, dst, src []byte) {
+	_ = src[BlockSize-1]
+	_ = dst[BlockSize-1]
+	x0 := binary.BigEndian.Uint32(src[0:4])
+	x1 := binary.BigEndian.Uint32(src[4:8])
+	x2 := binary.BigEndian.Uint32(src[8:12])
+	x3 := binary.BigEndian.Uint32(src[12:16])
+
+	for i := 0; i < 32; i += 4 {
+		x0 ^= tLookup(x1 ^ x2 ^ x3 ^ rk[i])
+		x1 ^= tLookup(x2 ^ x3 ^ x0 ^ rk[i+1])
+		x2 ^= tLookup(x3 ^ x0 ^ x1 ^ rk[i+2])
+		x3 ^= tLookup(x0 ^ x1 ^ x2 ^ rk[i+3])
+	}
+
+	binary.BigEndian.PutUint32(dst[0:4], x3)
+	binary.BigEndian.PutUint32(dst[4:8], x2)
+	binary.BigEndian.PutUint32(dst[8:12], x1)
+	binary.BigEndian.PutUint32(dst[12:16], x0)
 }
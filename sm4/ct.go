@@ -0,0 +1,94 @@
+package sm4
+
+import (
+	"encoding/binary"
+
+	"github.com/anhk/crypto/internal/gf256"
+)
+
+//Option - NewCipher 的可选参数
+type Option func(*SM4)
+
+//WithConstantTime - 使用常数时间实现: 支持时使用汇编实现，否则S盒由比特切片的 GF(2^8) 求逆和仿射变换计算，
+// 不以秘密数据为下标查表，可抵抗缓存计时攻击，速度比查表实现慢
+func WithConstantTime() Option {
+	return func(sm4 *SM4) {
+		sm4.constantTime = true
+	}
+}
+
+/**
+ * SM4 的S盒可分解为 S(x) = A·(A·x + 0xD3)^-1 + 0xD3
+ * - 有限域的约简多项式为 x^8 + x^7 + x^6 + x^5 + x^4 + x^2 + 1
+ * - A 的第 i 行: b_i ^ b_{i+1} ^ b_{i+2} ^ b_{i+5} ^ b_{i+7}
+ */
+func affineCT(x *gf256.Slice) (y gf256.Slice) {
+	for i := range y {
+		y[i] = x[i] ^ x[(i+1)%8] ^ x[(i+2)%8] ^ x[(i+5)%8] ^ x[(i+7)%8]
+		if 0xD3>>uint(i)&1 == 1 {
+			y[i] = ^y[i]
+		}
+	}
+	return
+}
+
+func sboxCT(x *gf256.Slice) gf256.Slice {
+	y := affineCT(x)
+	y = gf256.Inverse(&y, 0xF5)
+	return affineCT(&y)
+}
+
+func tauCT(b uint32) uint32 {
+	var in [4]byte
+	binary.BigEndian.PutUint32(in[:], b)
+	x := gf256.Load(in[:])
+	x = sboxCT(&x)
+	x.Store(in[:])
+	return binary.BigEndian.Uint32(in[:])
+}
+
+/**
+ * 比特切片最多容纳32个字节，即8个分组每轮的 S 盒输入，
+ * 因此常数时间实现每次并行处理8个分组
+ */
+const ctBatchSize = 8 * BlockSize
+
+/**
+ * 并行加解密 1 ~ 8 个分组
+ */
+func cryptBlocksCT(rk *[32]uint32, dst, src []byte) {
+	n := len(src) / BlockSize
+	var x [4][8]uint32
+	for b := 0; b < n; b++ {
+		for j := range x {
+			x[j][b] = binary.BigEndian.Uint32(src[b*BlockSize+j*4:])
+		}
+	}
+
+	var in [32]byte
+	for i := 0; i < 32; i++ {
+		for b := 0; b < n; b++ {
+			binary.BigEndian.PutUint32(in[b*4:], x[1][b]^x[2][b]^x[3][b]^rk[i])
+		}
+		s := gf256.Load(in[:n*4])
+		s = sboxCT(&s)
+		s.Store(in[:n*4])
+		for b := 0; b < n; b++ {
+			x[0][b] ^= l(binary.BigEndian.Uint32(in[b*4:]))
+		}
+		x[0], x[1], x[2], x[3] = x[1], x[2], x[3], x[0]
+	}
+
+	for b := 0; b < n; b++ {
+		for j := range x {
+			binary.BigEndian.PutUint32(dst[b*BlockSize+j*4:], x[3-j][b])
+		}
+	}
+}
+
+func (sm4 *SM4) tau(b uint32) uint32 {
+	if sm4.constantTime {
+		return tauCT(b)
+	}
+	return tau(b)
+}
@@ -0,0 +1,322 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/anhk/crypto/internal/alias"
+)
+
+const (
+	gcmStandardNonceSize = 12
+	gcmTagSize           = 16
+	gcmMinimumTagSize    = 12
+)
+
+var errOpen = errors.New("sm4: message authentication failed")
+
+/**
+ * GF(2^128) 中的元素，按 GCM 规范的比特顺序存放：
+ * low 的最高位为 x^0 的系数，high 的最低位为 x^127 的系数
+ */
+type gcmFieldElement struct {
+	low, high uint64
+}
+
+//sm4GCM - SM4 的 GCM 工作模式 (NIST SP 800-38D, RFC 8998)
+type sm4GCM struct {
+	cipher    *SM4
+	nonceSize int
+	tagSize   int
+
+	/**
+	 * H 的 0 ~ 15 倍乘积表，GHASH 每次处理4比特
+	 */
+	productTable [16]gcmFieldElement
+}
+
+//NewGCM - 创建 SM4-GCM，使用12字节的随机数和16字节的标签
+func NewGCM(key []byte) (cipher.AEAD, error) {
+	return newGCM(key, gcmStandardNonceSize, gcmTagSize)
+}
+
+//NewGCMWithNonceSize - 创建使用非标准随机数长度的 SM4-GCM
+func NewGCMWithNonceSize(key []byte, size int) (cipher.AEAD, error) {
+	return newGCM(key, size, gcmTagSize)
+}
+
+//NewGCMWithTagSize - 创建使用非标准标签长度(12 ~ 16 字节)的 SM4-GCM
+func NewGCMWithTagSize(key []byte, tagSize int) (cipher.AEAD, error) {
+	return newGCM(key, gcmStandardNonceSize, tagSize)
+}
+
+func newGCM(key []byte, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if tagSize < gcmMinimumTagSize || tagSize > BlockSize {
+		return nil, errors.New("sm4: incorrect tag size given to GCM")
+	}
+	if nonceSize <= 0 {
+		return nil, errors.New("sm4: the nonce can't have zero length")
+	}
+
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var h [BlockSize]byte
+	c.Encrypt(h[:], h[:])
+
+	g := &sm4GCM{cipher: c, nonceSize: nonceSize, tagSize: tagSize}
+	x := gcmFieldElement{
+		binary.BigEndian.Uint64(h[:8]),
+		binary.BigEndian.Uint64(h[8:]),
+	}
+	g.productTable[reverseBits(1)] = x
+	for i := 2; i < 16; i += 2 {
+		g.productTable[reverseBits(i)] = gcmDouble(&g.productTable[reverseBits(i/2)])
+		g.productTable[reverseBits(i+1)] = gcmAdd(&g.productTable[reverseBits(i)], &x)
+	}
+	return g, nil
+}
+
+func (g *sm4GCM) NonceSize() int {
+	return g.nonceSize
+}
+
+func (g *sm4GCM) Overhead() int {
+	return g.tagSize
+}
+
+func (g *sm4GCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != g.nonceSize {
+		panic("sm4: incorrect nonce length given to GCM")
+	}
+	if uint64(len(plaintext)) > ((1<<32)-2)*uint64(BlockSize) {
+		panic("sm4: message too large for GCM")
+	}
+
+	ret, out := alias.SliceForAppend(dst, len(plaintext)+g.tagSize)
+	if alias.InexactOverlap(out, plaintext) {
+		panic("sm4: invalid buffer overlap")
+	}
+
+	var counter, tagMask [BlockSize]byte
+	g.deriveCounter(&counter, nonce)
+
+	g.cipher.Encrypt(tagMask[:], counter[:])
+	gcmInc32(&counter)
+
+	g.counterCrypt(out, plaintext, &counter)
+
+	var tag [gcmTagSize]byte
+	g.auth(tag[:], out[:len(plaintext)], additionalData, &tagMask)
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+func (g *sm4GCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != g.nonceSize {
+		panic("sm4: incorrect nonce length given to GCM")
+	}
+	if len(ciphertext) < g.tagSize {
+		return nil, errOpen
+	}
+	if uint64(len(ciphertext)) > ((1<<32)-2)*uint64(BlockSize)+uint64(g.tagSize) {
+		return nil, errOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-g.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-g.tagSize]
+
+	var counter, tagMask [BlockSize]byte
+	g.deriveCounter(&counter, nonce)
+
+	g.cipher.Encrypt(tagMask[:], counter[:])
+	gcmInc32(&counter)
+
+	var expectedTag [gcmTagSize]byte
+	g.auth(expectedTag[:], ciphertext, additionalData, &tagMask)
+
+	ret, out := alias.SliceForAppend(dst, len(ciphertext))
+	if alias.InexactOverlap(out, ciphertext) {
+		panic("sm4: invalid buffer overlap")
+	}
+
+	if subtle.ConstantTimeCompare(expectedTag[:g.tagSize], tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+
+	g.counterCrypt(out, ciphertext, &counter)
+	return ret, nil
+}
+
+func reverseBits(i int) int {
+	i = ((i << 2) & 0xc) | ((i >> 2) & 0x3)
+	i = ((i << 1) & 0xa) | ((i >> 1) & 0x5)
+	return i
+}
+
+func gcmAdd(x, y *gcmFieldElement) gcmFieldElement {
+	return gcmFieldElement{x.low ^ y.low, x.high ^ y.high}
+}
+
+/**
+ * 乘以 x，即在 GCM 比特顺序下右移一位，溢出时异或约简多项式 x^128 + x^7 + x^2 + x + 1
+ */
+func gcmDouble(x *gcmFieldElement) (double gcmFieldElement) {
+	msbSet := x.high&1 == 1
+
+	double.high = x.high >> 1
+	double.high |= x.low << 63
+	double.low = x.low >> 1
+
+	if msbSet {
+		double.low ^= 0xe100000000000000
+	}
+	return
+}
+
+var gcmReductionTable = []uint16{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+/**
+ * y = y * H
+ */
+func (g *sm4GCM) mul(y *gcmFieldElement) {
+	var z gcmFieldElement
+
+	for i := 0; i < 2; i++ {
+		word := y.high
+		if i == 1 {
+			word = y.low
+		}
+
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high >>= 4
+			z.high |= z.low << 60
+			z.low >>= 4
+			z.low ^= uint64(gcmReductionTable[msw]) << 48
+
+			t := &g.productTable[word&0xf]
+
+			z.low ^= t.low
+			z.high ^= t.high
+			word >>= 4
+		}
+	}
+
+	*y = z
+}
+
+/**
+ * 将整块数据累加进 GHASH
+ */
+func (g *sm4GCM) updateBlocks(y *gcmFieldElement, blocks []byte) {
+	for len(blocks) > 0 {
+		y.low ^= binary.BigEndian.Uint64(blocks)
+		y.high ^= binary.BigEndian.Uint64(blocks[8:])
+		g.mul(y)
+		blocks = blocks[BlockSize:]
+	}
+}
+
+/**
+ * 累加任意长度的数据，不足一块时补0
+ */
+func (g *sm4GCM) update(y *gcmFieldElement, data []byte) {
+	fullBlocks := (len(data) >> 4) << 4
+	g.updateBlocks(y, data[:fullBlocks])
+
+	if len(data) != fullBlocks {
+		var partialBlock [BlockSize]byte
+		copy(partialBlock[:], data[fullBlocks:])
+		g.updateBlocks(y, partialBlock[:])
+	}
+}
+
+/**
+ * 计数器低32比特加1
+ */
+func gcmInc32(counterBlock *[BlockSize]byte) {
+	ctr := counterBlock[len(counterBlock)-4:]
+	binary.BigEndian.PutUint32(ctr, binary.BigEndian.Uint32(ctr)+1)
+}
+
+/**
+ * 每次批量加密的计数器块数
+ */
+const gcmBatchBlocks = 8
+
+func (g *sm4GCM) counterCrypt(out, in []byte, counter *[BlockSize]byte) {
+	var counters, mask [gcmBatchBlocks * BlockSize]byte
+
+	for len(in) > 0 {
+		n := (len(in) + BlockSize - 1) / BlockSize
+		if n > gcmBatchBlocks {
+			n = gcmBatchBlocks
+		}
+		for i := 0; i < n; i++ {
+			copy(counters[i*BlockSize:], counter[:])
+			gcmInc32(counter)
+		}
+		g.cipher.EncryptBlocks(mask[:n*BlockSize], counters[:n*BlockSize])
+
+		m := n * BlockSize
+		if m > len(in) {
+			m = len(in)
+		}
+		for i := 0; i < m; i++ {
+			out[i] = in[i] ^ mask[i]
+		}
+		out, in = out[m:], in[m:]
+	}
+}
+
+/**
+ * 初始计数器 J0
+ * - 12字节随机数: J0 = nonce || 0^31 || 1
+ * - 其他长度: J0 = GHASH(nonce || 0^s || [len(nonce)]64)
+ */
+func (g *sm4GCM) deriveCounter(counter *[BlockSize]byte, nonce []byte) {
+	if len(nonce) == gcmStandardNonceSize {
+		copy(counter[:], nonce)
+		counter[BlockSize-1] = 1
+	} else {
+		var y gcmFieldElement
+		g.update(&y, nonce)
+		y.high ^= uint64(len(nonce)) * 8
+		g.mul(&y)
+		binary.BigEndian.PutUint64(counter[:8], y.low)
+		binary.BigEndian.PutUint64(counter[8:], y.high)
+	}
+}
+
+/**
+ * tag = GHASH(A || C || [len(A)]64 || [len(C)]64) ^ E(K, J0)
+ */
+func (g *sm4GCM) auth(out, ciphertext, additionalData []byte, tagMask *[BlockSize]byte) {
+	var y gcmFieldElement
+	g.update(&y, additionalData)
+	g.update(&y, ciphertext)
+
+	y.low ^= uint64(len(additionalData)) * 8
+	y.high ^= uint64(len(ciphertext)) * 8
+
+	g.mul(&y)
+
+	binary.BigEndian.PutUint64(out, y.low)
+	binary.BigEndian.PutUint64(out[8:], y.high)
+
+	for i := range out {
+		out[i] ^= tagMask[i]
+	}
+}
@@ -0,0 +1,25 @@
+package sm4
+
+import (
+	"github.com/anhk/crypto/keywrap"
+)
+
+//WrapKey - 使用 SM4 密钥包装 key (RFC 3394 KW)，len(key) 为 8 的整数倍且不少于 16 字节
+func (sm4 *SM4) WrapKey(key []byte) ([]byte, error) {
+	return keywrap.Wrap(sm4, key)
+}
+
+//UnwrapKey - 解包 WrapKey 的结果，完整性校验失败时返回 keywrap.IntegrityError
+func (sm4 *SM4) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return keywrap.Unwrap(sm4, wrapped)
+}
+
+//WrapKeyWithPadding - 使用 SM4 密钥包装任意长度的 key (RFC 5649 KWP)
+func (sm4 *SM4) WrapKeyWithPadding(key []byte) ([]byte, error) {
+	return keywrap.WrapWithPadding(sm4, key)
+}
+
+//UnwrapKeyWithPadding - 解包 WrapKeyWithPadding 的结果，完整性校验失败时返回 keywrap.IntegrityError
+func (sm4 *SM4) UnwrapKeyWithPadding(wrapped []byte) ([]byte, error) {
+	return keywrap.UnwrapWithPadding(sm4, wrapped)
+}
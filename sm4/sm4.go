@@ -2,6 +2,8 @@ package sm4
 
 import (
 	"errors"
+
+	"github.com/anhk/crypto/internal/alias"
 )
 
 const (
@@ -11,13 +13,27 @@ const (
 
 type SM4 struct {
 	subKeys [32]uint32
+
+	/**
+	 * 解密轮密钥，即 subKeys 的逆序
+	 */
+	decKeys [32]uint32
+
+	/**
+	 * 是否使用常数时间实现，见 WithConstantTime
+	 */
+	constantTime bool
 }
 
-func NewCipher(key []byte) (*SM4, error) {
+//NewCipher - 默认使用查表实现，可通过 WithConstantTime 选择常数时间实现
+func NewCipher(key []byte, opts ...Option) (*SM4, error) {
 	if len(key) != KeySize {
 		return nil, errors.New("invalid size of key, only support 16 bytes.")
 	}
 	sm4 := &SM4{}
+	for _, opt := range opts {
+		opt(sm4)
+	}
 	sm4.keyExpansion(key)
 	return sm4, nil
 }
@@ -26,30 +42,107 @@ func (sm4 *SM4) keyExpansion(key []byte) {
 	b, _ := newBlock(key)
 	b.AddFk()
 	for i := 0; i < 32; i++ {
-		sm4.subKeys[i] = b[0] ^ tAp(b[1]^b[2]^b[3]^CK[i])
+		sm4.subKeys[i] = b[0] ^ sm4.tAp(b[1]^b[2]^b[3]^CK[i])
 		b[0] = sm4.subKeys[i]
 		b.LeftShift()
 	}
+	for i := 0; i < 32; i++ {
+		sm4.decKeys[i] = sm4.subKeys[31-i]
+	}
 }
 
+//Encrypt - 加密一个分组，不分配内存
 func (sm4 *SM4) Encrypt(dst, src []byte) {
-	b, _ := newBlock(src)
-	for i := 0; i < 32; i++ {
-		b[0] = b[0] ^ t(b[1]^b[2]^b[3]^sm4.subKeys[i])
-		b.LeftShift()
-	}
-	b.Rotate()
-	b.Write(dst)
+	sm4.cryptBlock(&sm4.subKeys, dst, src)
 }
 
+//Decrypt - 解密一个分组
 func (sm4 *SM4) Decrypt(dst, src []byte) {
-	b, _ := newBlock(src)
-	for i := 32; i > 0; i-- {
-		b[0] = b[0] ^ t(b[1]^b[2]^b[3]^sm4.subKeys[i-1])
-		b.LeftShift()
+	sm4.cryptBlock(&sm4.decKeys, dst, src)
+}
+
+//EncryptBlocks - 加密多个连续的分组，len(src) 必须为 BlockSize 的整数倍，
+// 供 CTR、GCM、XTS 等模式批量调用
+func (sm4 *SM4) EncryptBlocks(dst, src []byte) {
+	sm4.cryptBlocks(&sm4.subKeys, dst, src)
+}
+
+//DecryptBlocks - 解密多个连续的分组
+func (sm4 *SM4) DecryptBlocks(dst, src []byte) {
+	sm4.cryptBlocks(&sm4.decKeys, dst, src)
+}
+
+/**
+ * 汇编实现每次处理4个分组，单个分组时需补齐到4个分组，比查表实现慢，
+ * 因此单个分组只在要求常数时间时使用汇编实现
+ */
+const asmBatchSize = 4 * BlockSize
+
+func (sm4 *SM4) cryptBlock(rk *[32]uint32, dst, src []byte) {
+	if len(src) < BlockSize {
+		panic("sm4: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("sm4: output not full block")
+	}
+	if alias.InexactOverlap(dst[:BlockSize], src[:BlockSize]) {
+		panic("sm4: invalid buffer overlap")
+	}
+
+	if useAsm && sm4.constantTime {
+		var buf [asmBatchSize]byte
+		copy(buf[:], src[:BlockSize])
+		crypt4Asm(&rk[0], &buf[0], &buf[0])
+		copy(dst[:BlockSize], buf[:])
+		return
+	}
+	if sm4.constantTime {
+		cryptBlocksCT(rk, dst[:BlockSize], src[:BlockSize])
+		return
+	}
+	cryptBlock(rk, dst, src)
+}
+
+func (sm4 *SM4) cryptBlocks(rk *[32]uint32, dst, src []byte) {
+	if len(src)%BlockSize != 0 {
+		panic("sm4: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("sm4: output smaller than input")
+	}
+	if alias.InexactOverlap(dst[:len(src)], src) {
+		panic("sm4: invalid buffer overlap")
+	}
+
+	if useAsm {
+		for len(src) >= asmBatchSize {
+			crypt4Asm(&rk[0], &dst[0], &src[0])
+			dst, src = dst[asmBatchSize:], src[asmBatchSize:]
+		}
+		if len(src) > 0 {
+			var buf [asmBatchSize]byte
+			copy(buf[:], src)
+			crypt4Asm(&rk[0], &buf[0], &buf[0])
+			copy(dst, buf[:len(src)])
+		}
+		return
+	}
+
+	if sm4.constantTime {
+		for len(src) > 0 {
+			n := len(src)
+			if n > ctBatchSize {
+				n = ctBatchSize
+			}
+			cryptBlocksCT(rk, dst[:n], src[:n])
+			dst, src = dst[n:], src[n:]
+		}
+		return
+	}
+
+	for i := 0; i < len(src); i += BlockSize {
+		cryptBlock(rk, dst[i:], src[i:])
 	}
-	b.Rotate()
-	b.Write(dst)
 }
 
 func (sm4 *SM4) BlockSize() int {
@@ -0,0 +1,17 @@
+package sm4
+
+import (
+	"github.com/anhk/crypto/internal/cpuid"
+)
+
+/**
+ * 支持 AES-NI 和 SSSE3 时使用汇编实现: 借助 AESENCLAST 和仿射变换计算 S 盒，每次并行处理4个分组
+ * 该实现不以秘密数据为下标访问内存，也是常数时间的
+ * useAsm 可在测试中关闭，以便同时测试纯 Go 实现
+ */
+var supportsAsm = cpuid.HasAES && cpuid.HasSSSE3
+
+var useAsm = supportsAsm
+
+//go:noescape
+func crypt4Asm(rk *uint32, dst, src *byte)
@@ -0,0 +1,149 @@
+#include "textflag.h"
+
+// SM4 S盒: S(x) = post(AESSubBytes(pre(x)))
+// pre/post 为仿射变换，由低、高4比特两张 PSHUFB 表查得;
+// 先做逆 ShiftRows 以抵消 AESENCLAST 中的 ShiftRows，轮密钥为0
+DATA preL<>+0x00(SB)/8, $0x078b37bb820eb23e
+DATA preL<>+0x08(SB)/8, $0x9814a8241d912da1
+GLOBL preL<>(SB), (NOPTR+RODATA), $16
+
+DATA preH<>+0x00(SB)/8, $0x37eb19c5f22edc00
+DATA preH<>+0x08(SB)/8, $0x3fe311cdfa26d408
+GLOBL preH<>(SB), (NOPTR+RODATA), $16
+
+DATA postL<>+0x00(SB)/8, $0x2098ea521ea6d46c
+DATA postL<>+0x08(SB)/8, $0x47ff8d3579c1b30b
+GLOBL postL<>(SB), (NOPTR+RODATA), $16
+
+DATA postH<>+0x00(SB)/8, $0x2dcd7d9db050e000
+DATA postH<>+0x08(SB)/8, $0xed0dbd5d709020c0
+GLOBL postH<>(SB), (NOPTR+RODATA), $16
+
+DATA invShiftRows<>+0x00(SB)/8, $0x0b0e0104070a0d00
+DATA invShiftRows<>+0x08(SB)/8, $0x0306090c0f020508
+GLOBL invShiftRows<>(SB), (NOPTR+RODATA), $16
+
+DATA bswap32<>+0x00(SB)/8, $0x0405060700010203
+DATA bswap32<>+0x08(SB)/8, $0x0c0d0e0f08090a0b
+GLOBL bswap32<>(SB), (NOPTR+RODATA), $16
+
+DATA nibble<>+0x00(SB)/8, $0x0f0f0f0f0f0f0f0f
+DATA nibble<>+0x08(SB)/8, $0x0f0f0f0f0f0f0f0f
+GLOBL nibble<>(SB), (NOPTR+RODATA), $16
+// x = S(x)，t1、t2 被修改
+#define SBOX(x, t1, t2) \
+	MOVOU  x, t1;          \
+	PAND   X8, t1;         \
+	MOVOU  X9, t2;         \
+	PSHUFB t1, t2;         \
+	PSRLQ  $4, x;          \
+	PAND   X8, x;          \
+	MOVOU  X10, t1;        \
+	PSHUFB x, t1;          \
+	PXOR   t2, t1;         \
+	PSHUFB X13, t1;        \
+	AESENCLAST X15, t1;    \
+	MOVOU  t1, t2;         \
+	PAND   X8, t2;         \
+	MOVOU  X11, x;         \
+	PSHUFB t2, x;          \
+	PSRLQ  $4, t1;         \
+	PAND   X8, t1;         \
+	MOVOU  X12, t2;        \
+	PSHUFB t1, t2;         \
+	PXOR   t2, x
+
+// dst = src <<< n，tmp 被修改
+#define ROTL(n, m, src, dst, tmp) \
+	MOVOU src, dst; \
+	MOVOU src, tmp; \
+	PSLLL $n, dst;  \
+	PSRLL $m, tmp;  \
+	POR   tmp, dst
+
+// x = L(x) = x ^ (x <<< 24) ^ ((x ^ (x <<< 8) ^ (x <<< 16)) <<< 2)
+#define LTRANS(x, t1, t2, t3) \
+	ROTL(8, 24, x, t1, t3);  \
+	PXOR x, t1;              \
+	ROTL(16, 16, x, t2, t3); \
+	PXOR t2, t1;             \
+	ROTL(2, 30, t1, t2, t3); \
+	ROTL(24, 8, x, t1, t3);  \
+	PXOR t1, x;              \
+	PXOR t2, x
+
+// x0 ^= T(x1 ^ x2 ^ x3 ^ rk)
+#define ROUND(off, x0, x1, x2, x3) \
+	MOVSS  off(AX), X4;    \
+	PSHUFD $0, X4, X4;     \
+	PXOR   x1, X4;         \
+	PXOR   x2, X4;         \
+	PXOR   x3, X4;         \
+	SBOX(X4, X5, X6);      \
+	LTRANS(X4, X5, X6, X7); \
+	PXOR   X4, x0
+
+// 4x4 的32位字矩阵转置
+#define TRANSPOSE(r0, r1, r2, r3, t0, t1) \
+	MOVOU      r0, t0; \
+	PUNPCKLLQ  r1, r0; \
+	PUNPCKHLQ  r1, t0; \
+	MOVOU      r2, t1; \
+	PUNPCKLLQ  r3, r2; \
+	PUNPCKHLQ  r3, t1; \
+	MOVOU      r0, r1; \
+	PUNPCKLQDQ r2, r0; \
+	PUNPCKHQDQ r2, r1; \
+	MOVOU      t0, r2; \
+	PUNPCKLQDQ t1, r2; \
+	PUNPCKHQDQ t1, t0; \
+	MOVOU      t0, r3
+
+// func crypt4Asm(rk *uint32, dst, src *byte)
+// 并行处理4个分组，每个 XMM 寄存器的4个32位字分别属于4个分组
+TEXT ·crypt4Asm(SB), NOSPLIT, $0-24
+	MOVQ rk+0(FP), AX
+	MOVQ dst+8(FP), DI
+	MOVQ src+16(FP), SI
+
+	MOVOU nibble<>(SB), X8
+	MOVOU preL<>(SB), X9
+	MOVOU preH<>(SB), X10
+	MOVOU postL<>(SB), X11
+	MOVOU postH<>(SB), X12
+	MOVOU invShiftRows<>(SB), X13
+	MOVOU bswap32<>(SB), X14
+	PXOR  X15, X15
+
+	MOVOU  0(SI), X0
+	MOVOU  16(SI), X1
+	MOVOU  32(SI), X2
+	MOVOU  48(SI), X3
+	PSHUFB X14, X0
+	PSHUFB X14, X1
+	PSHUFB X14, X2
+	PSHUFB X14, X3
+	TRANSPOSE(X0, X1, X2, X3, X4, X5)
+
+	MOVQ $8, CX
+
+loop:
+	ROUND(0, X0, X1, X2, X3)
+	ROUND(4, X1, X2, X3, X0)
+	ROUND(8, X2, X3, X0, X1)
+	ROUND(12, X3, X0, X1, X2)
+	ADDQ $16, AX
+	DECQ CX
+	JNZ  loop
+
+	// 输出为反序 (X35, X34, X33, X32)
+	TRANSPOSE(X3, X2, X1, X0, X4, X5)
+	PSHUFB X14, X3
+	PSHUFB X14, X2
+	PSHUFB X14, X1
+	PSHUFB X14, X0
+	MOVOU  X3, 0(DI)
+	MOVOU  X2, 16(DI)
+	MOVOU  X1, 32(DI)
+	MOVOU  X0, 48(DI)
+	RET
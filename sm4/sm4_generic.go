@@ -0,0 +1,12 @@
+//go:build !amd64
+// +build !amd64
+
+package sm4
+
+const supportsAsm = false
+
+var useAsm = false
+
+func crypt4Asm(rk *uint32, dst, src *byte) {
+	panic("sm4: assembly not supported")
+}
@@ -2,7 +2,14 @@ package sm4
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/anhk/crypto/keywrap"
 )
 
 var key = [16]byte{
@@ -31,6 +38,56 @@ var roundKey = []uint32{
 	1288133630, 3121992281, 1516397203, 2363740549, 2173319383, 3013317934, 926979705, 3562538709,
 }
 
+/**
+ * 依次使用纯 Go 实现和汇编实现 (CPU 支持时) 运行测试
+ */
+func forEachImplementation(t *testing.T, f func(t *testing.T)) {
+	defer func(saved bool) { useAsm = saved }(useAsm)
+
+	useAsm = false
+	t.Run("generic", f)
+	if supportsAsm {
+		useAsm = true
+		t.Run("asm", f)
+	}
+}
+
+/**
+ * 汇编实现与纯 Go 实现比较
+ */
+func TestAsm(t *testing.T) {
+	if !supportsAsm {
+		t.Skip("assembly not supported")
+	}
+	defer func(saved bool) { useAsm = saved }(useAsm)
+
+	k := make([]byte, KeySize)
+	src := make([]byte, 9*BlockSize)
+	for i := 0; i < 100; i++ {
+		rand.Read(k)
+		rand.Read(src)
+		sm4, _ := NewCipher(k)
+		for _, n := range []int{BlockSize, 4 * BlockSize, len(src)} {
+			generic, asm := make([]byte, n), make([]byte, n)
+			useAsm = false
+			sm4.EncryptBlocks(generic, src[:n])
+			useAsm = true
+			sm4.EncryptBlocks(asm, src[:n])
+			if !bytes.Equal(generic, asm) {
+				t.Fatalf("encrypt mismatch with key %x", k)
+			}
+
+			useAsm = false
+			sm4.DecryptBlocks(generic, src[:n])
+			useAsm = true
+			sm4.DecryptBlocks(asm, src[:n])
+			if !bytes.Equal(generic, asm) {
+				t.Fatalf("decrypt mismatch with key %x", k)
+			}
+		}
+	}
+}
+
 func TestNewCipher(t *testing.T) {
 	sm4, _ := NewCipher(key[:])
 	for i := 0; i < len(sm4.subKeys); i++ {
@@ -42,10 +99,13 @@ func TestNewCipher(t *testing.T) {
 
 /**
  */
-func TestSM4_Encrypt(t *testing.T) {
+func TestSM4_Encrypt(t *testing.T) { forEachImplementation(t, testSM4_Encrypt) }
+
+func testSM4_Encrypt(t *testing.T) {
 	sm4, _ := NewCipher(key[:])
-	sm4.Encrypt(data, data)
-	if !bytes.Equal(data, encData) {
+	buf := append([]byte{}, data...)
+	sm4.Encrypt(buf, buf)
+	if !bytes.Equal(buf, encData) {
 		t.Fatal("invalid encrypt")
 	}
 }
@@ -53,10 +113,360 @@ func TestSM4_Encrypt(t *testing.T) {
 /*
 [169 26 110 209 73 232 62 90 157 139 129 136 5 200 26 249]
 */
-func TestSM4_Decrypt(t *testing.T) {
+func TestSM4_Decrypt(t *testing.T) { forEachImplementation(t, testSM4_Decrypt) }
+
+func testSM4_Decrypt(t *testing.T) {
 	sm4, _ := NewCipher(key[:])
-	sm4.Decrypt(data, data)
-	if !bytes.Equal(data, decData) {
+	buf := append([]byte{}, data...)
+	sm4.Decrypt(buf, buf)
+	if !bytes.Equal(buf, decData) {
 		t.Fatal("invalid decrypt")
 	}
 }
+
+func TestConstantTimeSBox(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		b := uint32(i) * 0x01010101
+		if tauCT(b) != tau(b) {
+			t.Fatalf("S(%02x) = %08x, want %08x", i, tauCT(b), tau(b))
+		}
+	}
+}
+
+/**
+ * 常数时间实现与查表实现比较
+ */
+func TestConstantTime(t *testing.T) { forEachImplementation(t, testConstantTime) }
+
+func testConstantTime(t *testing.T) {
+	/**
+	 * GB/T 32907 附录A 示例1
+	 */
+	k := mustHex("0123456789abcdeffedcba9876543210")
+	sm4, _ := NewCipher(k, WithConstantTime())
+	buf := make([]byte, BlockSize)
+	sm4.Encrypt(buf, k)
+	if hex.EncodeToString(buf) != "681edf34d206965e86b3e94f536e4246" {
+		t.Fatal("invalid encrypt")
+	}
+
+	src := make([]byte, BlockSize)
+	for i := 0; i < 100; i++ {
+		rand.Read(k)
+		rand.Read(src)
+		table, _ := NewCipher(k)
+		ct, _ := NewCipher(k, WithConstantTime())
+		if ct.subKeys != table.subKeys {
+			t.Fatalf("key expansion mismatch with key %x", k)
+		}
+
+		var got, want [BlockSize]byte
+		ct.Encrypt(got[:], src)
+		table.Encrypt(want[:], src)
+		if got != want {
+			t.Fatalf("encrypt mismatch with key %x", k)
+		}
+
+		ct.Decrypt(got[:], src)
+		table.Decrypt(want[:], src)
+		if got != want {
+			t.Fatalf("decrypt mismatch with key %x", k)
+		}
+	}
+}
+
+/**
+ * 批量接口与逐块接口比较，包括常数时间实现跨8个分组的边界
+ */
+func TestEncryptBlocks(t *testing.T) { forEachImplementation(t, testEncryptBlocks) }
+
+func testEncryptBlocks(t *testing.T) {
+	src := make([]byte, 20*BlockSize)
+	rand.Read(src)
+
+	for _, opts := range [][]Option{nil, {WithConstantTime()}} {
+		sm4, _ := NewCipher(key[:], opts...)
+		for n := 0; n <= len(src); n += BlockSize {
+			want := make([]byte, n)
+			for i := 0; i < n; i += BlockSize {
+				sm4.Encrypt(want[i:], src[i:])
+			}
+
+			got := make([]byte, n)
+			sm4.EncryptBlocks(got, src[:n])
+			if !bytes.Equal(got, want) {
+				t.Fatal("EncryptBlocks mismatch,", n/BlockSize, "blocks")
+			}
+
+			sm4.DecryptBlocks(got, got)
+			if !bytes.Equal(got, src[:n]) {
+				t.Fatal("DecryptBlocks mismatch,", n/BlockSize, "blocks")
+			}
+		}
+	}
+
+	sm4, _ := NewCipher(key[:])
+	mustPanic(t, "partial block", func() { sm4.EncryptBlocks(src, src[:17]) })
+	mustPanic(t, "short output", func() { sm4.DecryptBlocks(src[:16], src[:32]) })
+	mustPanic(t, "overlap", func() { sm4.EncryptBlocks(src[16:], src[:64]) })
+	sm4.EncryptBlocks(src[:64], src[64:128])
+}
+
+/**
+ * 与 crypto/cipher.Block 一致: 不足一个分组或错位重叠时 panic
+ */
+func TestInvalidBuffers(t *testing.T) { forEachImplementation(t, testInvalidBuffers) }
+
+func testInvalidBuffers(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithConstantTime()}} {
+		sm4, _ := NewCipher(key[:], opts...)
+		buf := make([]byte, 2*BlockSize)
+		for _, crypt := range []func(dst, src []byte){sm4.Encrypt, sm4.Decrypt} {
+			mustPanic(t, "short input", func() { crypt(buf, buf[:BlockSize-1]) })
+			mustPanic(t, "short output", func() { crypt(buf[:BlockSize-1], buf[BlockSize:]) })
+			mustPanic(t, "overlap", func() { crypt(buf[1:], buf) })
+			mustPanic(t, "overlap", func() { crypt(buf, buf[BlockSize-1:]) })
+
+			crypt(buf, buf)
+			crypt(buf[:BlockSize], buf[BlockSize:])
+		}
+	}
+}
+
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal(name, "did not panic")
+		}
+	}()
+	f()
+}
+
+func TestEncryptAllocs(t *testing.T) { forEachImplementation(t, testEncryptAllocs) }
+
+func testEncryptAllocs(t *testing.T) {
+	sm4, _ := NewCipher(key[:])
+	buf := make([]byte, 8*BlockSize)
+	n := testing.AllocsPerRun(100, func() {
+		sm4.Encrypt(buf, buf)
+		sm4.Decrypt(buf, buf)
+		sm4.EncryptBlocks(buf, buf)
+		sm4.DecryptBlocks(buf, buf)
+	})
+	if n != 0 {
+		t.Fatal("unexpected allocations:", n)
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, opts ...Option) {
+	sm4, _ := NewCipher(key[:], opts...)
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sm4.Encrypt(buf, buf)
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B)             { benchmarkEncrypt(b) }
+func BenchmarkEncryptConstantTime(b *testing.B) { benchmarkEncrypt(b, WithConstantTime()) }
+
+func benchmarkEncryptBlocks(b *testing.B, size int, opts ...Option) {
+	sm4, _ := NewCipher(key[:], opts...)
+	buf := make([]byte, size)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		sm4.EncryptBlocks(buf, buf)
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*size), "ns/B")
+}
+
+func BenchmarkEncryptBlocks1K(b *testing.B) { benchmarkEncryptBlocks(b, 1024) }
+func BenchmarkEncryptBlocks8K(b *testing.B) { benchmarkEncryptBlocks(b, 8192) }
+func BenchmarkEncryptBlocksConstantTime1K(b *testing.B) {
+	benchmarkEncryptBlocks(b, 1024, WithConstantTime())
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * RFC 8998 附录 A.1 SM4-GCM 测试向量
+ */
+func TestNewGCM(t *testing.T) { forEachImplementation(t, testNewGCM) }
+
+func testNewGCM(t *testing.T) {
+	key := mustHex("0123456789ABCDEFFEDCBA9876543210")
+	nonce := mustHex("00001234567800000000ABCD")
+	aad := mustHex("FEEDFACEDEADBEEFFEEDFACEDEADBEEFABADDAD2")
+	plain := mustHex("AAAAAAAAAAAAAAAABBBBBBBBBBBBBBBBCCCCCCCCCCCCCCCCDDDDDDDDDDDDDDDD" +
+		"EEEEEEEEEEEEEEEEFFFFFFFFFFFFFFFFEEEEEEEEEEEEEEEEAAAAAAAAAAAAAAAA")
+	expected := mustHex("17F399F08C67D5EE19D0DC9969C4BB7D5FD46FD3756489069157B282BB200735" +
+		"D82710CA5C22F0CCFA7CBF93D496AC15A56834CBCF98C397B4024A2691233B8D" +
+		"83DE3541E4C2B58177E065A9BF7B62EC")
+
+	aead, err := NewGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := aead.Seal(nil, nonce, plain, aad)
+	if !bytes.Equal(ct, expected) {
+		t.Fatal("invalid seal")
+	}
+
+	pt, err := aead.Open(nil, nonce, ct, aad)
+	if err != nil || !bytes.Equal(pt, plain) {
+		t.Fatal("invalid open")
+	}
+
+	ct[0] ^= 1
+	if _, err := aead.Open(nil, nonce, ct, aad); err == nil {
+		t.Fatal("open succeeded with a modified cipher text")
+	}
+}
+
+/**
+ * 与标准库的通用 GCM 实现比较非标准随机数及标签长度
+ */
+func TestGCMSizes(t *testing.T) { forEachImplementation(t, testGCMSizes) }
+
+func testGCMSizes(t *testing.T) {
+	block, _ := NewCipher(key[:])
+	aad := []byte("additional data")
+	for _, nonceSize := range []int{1, 8, 12, 16, 60} {
+		for _, tagSize := range []int{12, 13, 16} {
+			g, err := newGCM(key[:], nonceSize, tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var std cipher.AEAD
+			if tagSize == gcmTagSize {
+				std, _ = cipher.NewGCMWithNonceSize(block, nonceSize)
+			} else if nonceSize == gcmStandardNonceSize {
+				std, _ = cipher.NewGCMWithTagSize(block, tagSize)
+			}
+
+			nonce := make([]byte, nonceSize)
+			rand.Read(nonce)
+			for _, n := range []int{0, 1, 16, 17, 100, 127, 128, 129, 300} {
+				plain := make([]byte, n)
+				rand.Read(plain)
+
+				ct := g.Seal(nil, nonce, plain, aad)
+				if len(ct) != n+tagSize {
+					t.Fatal("invalid cipher text length")
+				}
+				if std != nil && !bytes.Equal(ct, std.Seal(nil, nonce, plain, aad)) {
+					t.Fatalf("nonce %d, tag %d, len %d: mismatch with crypto/cipher", nonceSize, tagSize, n)
+				}
+				pt, err := g.Open(ct[:0], nonce, ct, aad)
+				if err != nil || !bytes.Equal(pt, plain) {
+					t.Fatal("invalid open")
+				}
+			}
+		}
+	}
+
+	if _, err := NewGCMWithTagSize(key[:], 8); err == nil {
+		t.Fatal("short tag size should fail")
+	}
+	if _, err := NewGCMWithNonceSize(key[:], 0); err == nil {
+		t.Fatal("empty nonce should fail")
+	}
+}
+
+func BenchmarkGCMSeal1K(b *testing.B) {
+	aead, _ := NewGCM(key[:])
+	nonce := make([]byte, aead.NonceSize())
+	buf := make([]byte, 1024)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		aead.Seal(buf[:0], nonce, buf[:1024-aead.Overhead()], nil)
+	}
+}
+
+/**
+ * RFC 8998 附录 A.2 SM4-CCM 测试向量
+ */
+func TestNewCCM(t *testing.T) {
+	key := mustHex("0123456789ABCDEFFEDCBA9876543210")
+	nonce := mustHex("00001234567800000000ABCD")
+	aad := mustHex("FEEDFACEDEADBEEFFEEDFACEDEADBEEFABADDAD2")
+	plain := mustHex("AAAAAAAAAAAAAAAABBBBBBBBBBBBBBBBCCCCCCCCCCCCCCCCDDDDDDDDDDDDDDDD" +
+		"EEEEEEEEEEEEEEEEFFFFFFFFFFFFFFFFEEEEEEEEEEEEEEEEAAAAAAAAAAAAAAAA")
+	expected := mustHex("48AF93501FA62ADBCD414CCE6034D895DDA1BF8F132F042098661572E7483094" +
+		"FD12E518CE062C98ACEE28D95DF4416BED31A2F04476C18BB40C84A74B97DC5B" +
+		"16842D4FA186F56AB33256971FA110F4")
+
+	aead, err := NewCCM(key, len(nonce), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := aead.Seal(nil, nonce, plain, aad)
+	if !bytes.Equal(ct, expected) {
+		t.Fatal("invalid seal")
+	}
+
+	pt, err := aead.Open(nil, nonce, ct, aad)
+	if err != nil || !bytes.Equal(pt, plain) {
+		t.Fatal("invalid open")
+	}
+}
+
+/**
+ * 期望值由只使用 OpenSSL SM4-ECB 的脚本计算: sh testdata/keywrap.sh
+ */
+func TestWrapKey(t *testing.T) { forEachImplementation(t, testWrapKey) }
+
+func testWrapKey(t *testing.T) {
+	dataKey := mustHex("00112233445566778899aabbccddeeff")
+	sm4, _ := NewCipher(mustHex("0123456789abcdeffedcba9876543210"))
+	wrapped, err := sm4.WrapKey(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(wrapped) != "2f92140188bb01970a726046b111c5fa427ced34d73dcab8" {
+		t.Fatalf("invalid wrap %x", wrapped)
+	}
+	if out, err := sm4.UnwrapKey(wrapped); err != nil || !bytes.Equal(out, dataKey) {
+		t.Fatal("invalid unwrap")
+	}
+
+	dataKey = mustHex("00112233445566778899aabbccddeeff0001020304050607")
+	wrapped, _ = sm4.WrapKey(dataKey)
+	if hex.EncodeToString(wrapped) != "5a825ad1efecd64d5f44dd8dbd9676a5195b0cb135cf50477fe5ce65f2d73e16" {
+		t.Fatalf("invalid wrap %x", wrapped)
+	}
+
+	dataKey = mustHex("c37b7e6492584340bed12207808941155068f738")
+	wrapped, _ = sm4.WrapKeyWithPadding(dataKey)
+	if hex.EncodeToString(wrapped) != "134dfdd962bf2450d070aba893ea8af7b82a2316b6a34ceb9ab456a5bd5fac44" {
+		t.Fatalf("invalid padded wrap %x", wrapped)
+	}
+	if out, err := sm4.UnwrapKeyWithPadding(wrapped); err != nil || !bytes.Equal(out, dataKey) {
+		t.Fatal("invalid padded unwrap")
+	}
+
+	wrapped, _ = sm4.WrapKeyWithPadding([]byte("ForPasi"))
+	if hex.EncodeToString(wrapped) != "43e3b77a56dc8fe9cf577906ab0bfb1a" {
+		t.Fatalf("invalid padded wrap %x", wrapped)
+	}
+	if out, err := sm4.UnwrapKeyWithPadding(wrapped); err != nil || string(out) != "ForPasi" {
+		t.Fatal("invalid padded unwrap")
+	}
+
+	other, _ := NewCipher(make([]byte, KeySize))
+	var integrityErr keywrap.IntegrityError
+	if _, err := other.UnwrapKeyWithPadding(wrapped); !errors.As(err, &integrityErr) {
+		t.Fatal("expected IntegrityError, got", err)
+	}
+}
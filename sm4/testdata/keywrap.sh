@@ -0,0 +1,52 @@
+#!/bin/sh
+# SM4 密钥包装 KW (RFC 3394) / KWP (RFC 5649) 的期望值，只使用 OpenSSL 的 SM4 分组运算计算
+# 包装密钥为 0123456789abcdeffedcba9876543210
+#
+#   sh testdata/keywrap.sh
+set -e
+
+KEK=0123456789abcdeffedcba9876543210
+
+hex() { xxd -p | tr -d '\n'; }
+enc() { printf '%s' "$1" | xxd -r -p | openssl enc -sm4-ecb -nopad -K $KEK | hex; }
+
+# 包装函数 W，参数为 A(16个十六进制字符) 和 R[1] || ... || R[n]
+wrap() {
+	a=$1
+	r=$2
+	n=$((${#r} / 16))
+	j=0
+	while [ $j -lt 6 ]; do
+		i=1
+		out=
+		while [ $i -le $n ]; do
+			ri=$(printf '%s' "$r" | cut -c$(((i - 1) * 16 + 1))-$((i * 16)))
+			b=$(enc "$a$ri")
+			t=$((n * j + i))
+			# t 小于 2^32，只影响 A 的低 32 比特
+			a=$(printf '%s' "$b" | head -c 8)$(printf '%08x' $((0x$(printf '%s' "$b" | cut -c9-16) ^ t)))
+			r=$(printf '%s' "$r" | head -c $(((i - 1) * 16)))$(printf '%s' "$b" | tail -c 16)$(printf '%s' "$r" | tail -c +$((i * 16 + 1)))
+			i=$((i + 1))
+		done
+		j=$((j + 1))
+	done
+	printf '%s%s\n' "$a" "$r"
+}
+
+# KWP: AIV = A65959A6 || MLI，补0至 8 字节的整数倍，只有一个半块时直接加密
+wrap_pad() {
+	aiv=a65959a6$(printf '%08x' $((${#1} / 2)))
+	p=$1
+	while [ $((${#p} % 16)) -ne 0 ]; do p=${p}00; done
+	if [ ${#p} -eq 16 ]; then
+		enc "$aiv$p"
+		echo
+	else
+		wrap "$aiv" "$p"
+	fi
+}
+
+wrap a6a6a6a6a6a6a6a6 00112233445566778899aabbccddeeff
+wrap a6a6a6a6a6a6a6a6 00112233445566778899aabbccddeeff0001020304050607
+wrap_pad c37b7e6492584340bed12207808941155068f738
+wrap_pad "$(printf 'ForPasi' | hex)"
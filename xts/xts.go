@@ -0,0 +1,253 @@
+package xts
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"github.com/anhk/crypto/internal/alias"
+)
+
+const (
+	blockSize = 16
+
+	/**
+	 * 批量处理的分组数
+	 */
+	batchBlocks = 8
+)
+
+/**
+ * 支持批量加解密的分组密码，例如 sm4.SM4
+ */
+type blocksCipher interface {
+	EncryptBlocks(dst, src []byte)
+	DecryptBlocks(dst, src []byte)
+}
+
+/**
+ * XTS 工作模式 (IEEE 1619, GB/T 17964-2021)
+ * key = Key1 || Key2，Key1 加密数据，Key2 加密调柄(tweak)
+ *
+ * 两种规范的区别在于调柄乘 α 时的比特顺序:
+ * - IEEE 1619: tweak[0] 的最低位为 x^0 的系数，左移，约简值 0x87
+ * - GB/T 17964: tweak[0] 的最高位为 x^0 的系数，右移，约简值 0xE1
+ */
+type Cipher struct {
+	k1, k2 cipher.Block
+	gb     bool
+}
+
+//NewCipher - 创建 IEEE 1619 XTS，cipherFunc 例如:
+//   func(key []byte) (cipher.Block, error) { return sm4.NewCipher(key) }
+func NewCipher(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*Cipher, error) {
+	return newCipher(cipherFunc, key, false)
+}
+
+//NewGBCipher - 创建 GB/T 17964 XTS
+func NewGBCipher(cipherFunc func([]byte) (cipher.Block, error), key []byte) (*Cipher, error) {
+	return newCipher(cipherFunc, key, true)
+}
+
+func newCipher(cipherFunc func([]byte) (cipher.Block, error), key []byte, gb bool) (*Cipher, error) {
+	if len(key) == 0 || len(key)%2 != 0 {
+		return nil, errors.New("xts: invalid key size")
+	}
+
+	c := &Cipher{gb: gb}
+	var err error
+	if c.k1, err = cipherFunc(key[:len(key)/2]); err != nil {
+		return nil, err
+	}
+	if c.k2, err = cipherFunc(key[len(key)/2:]); err != nil {
+		return nil, err
+	}
+	if c.k1.BlockSize() != blockSize {
+		return nil, errors.New("xts: cipher does not have a block size of 16")
+	}
+	return c, nil
+}
+
+/**
+ * 扇区号转换为调柄
+ * - IEEE 1619: 128比特小端序
+ * - GB/T 17964: 128比特大端序
+ */
+func (c *Cipher) sectorTweak(sectorNum uint64) (tweak [blockSize]byte) {
+	if c.gb {
+		binary.BigEndian.PutUint64(tweak[8:], sectorNum)
+	} else {
+		binary.LittleEndian.PutUint64(tweak[:8], sectorNum)
+	}
+	return
+}
+
+//EncryptSector - 加密一个扇区，长度不小于16字节，非整块时使用密文挪用
+func (c *Cipher) EncryptSector(ciphertext, plaintext []byte, sectorNum uint64) {
+	c.Encrypt(ciphertext, plaintext, c.sectorTweak(sectorNum))
+}
+
+//DecryptSector - 解密一个扇区
+func (c *Cipher) DecryptSector(plaintext, ciphertext []byte, sectorNum uint64) {
+	c.Decrypt(plaintext, ciphertext, c.sectorTweak(sectorNum))
+}
+
+func (c *Cipher) check(dst, src []byte) {
+	if len(src) < blockSize {
+		panic("xts: input not at least one block")
+	}
+	if len(dst) < len(src) {
+		panic("xts: output smaller than input")
+	}
+	if alias.InexactOverlap(dst[:len(src)], src) {
+		panic("xts: invalid buffer overlap")
+	}
+}
+
+/**
+ * C = E(K1, P ^ T) ^ T
+ */
+func (c *Cipher) cryptBlock(crypt func(dst, src []byte), dst, src []byte, tweak *[blockSize]byte) {
+	var x [blockSize]byte
+	for i := range x {
+		x[i] = src[i] ^ tweak[i]
+	}
+	crypt(x[:], x[:])
+	for i := range x {
+		dst[i] = x[i] ^ tweak[i]
+	}
+}
+
+/**
+ * 处理整块数据，每块之后 T = T ⊗ α
+ * 分组密码支持批量接口时，先计算一批调柄，再一次加解密多个分组
+ */
+func (c *Cipher) cryptBlocks(encrypt bool, dst, src []byte, tweak *[blockSize]byte) {
+	bc, ok := c.k1.(blocksCipher)
+	if !ok {
+		crypt := c.k1.Decrypt
+		if encrypt {
+			crypt = c.k1.Encrypt
+		}
+		for i := 0; i < len(src); i += blockSize {
+			c.cryptBlock(crypt, dst[i:], src[i:], tweak)
+			c.mulAlpha(tweak)
+		}
+		return
+	}
+
+	var tweaks, buf [batchBlocks * blockSize]byte
+	for len(src) > 0 {
+		n := len(src)
+		if n > len(buf) {
+			n = len(buf)
+		}
+		for i := 0; i < n; i += blockSize {
+			copy(tweaks[i:], tweak[:])
+			c.mulAlpha(tweak)
+		}
+		for i := 0; i < n; i++ {
+			buf[i] = src[i] ^ tweaks[i]
+		}
+		if encrypt {
+			bc.EncryptBlocks(buf[:n], buf[:n])
+		} else {
+			bc.DecryptBlocks(buf[:n], buf[:n])
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = buf[i] ^ tweaks[i]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+//Encrypt - 使用给定的128比特调柄加密，调柄先经 Key2 加密后参与运算
+func (c *Cipher) Encrypt(ciphertext, plaintext []byte, tweak [blockSize]byte) {
+	c.check(ciphertext, plaintext)
+	c.k2.Encrypt(tweak[:], tweak[:])
+
+	n := len(plaintext) / blockSize * blockSize
+	r := len(plaintext) - n
+	if r != 0 {
+		n -= blockSize
+	}
+
+	c.cryptBlocks(true, ciphertext[:n], plaintext[:n], &tweak)
+
+	/**
+	 * 密文挪用:
+	 * CC = E(Pm, Tm)
+	 * Cm+1 = CC[:r]
+	 * Cm = E(Pm+1 || CC[r:], Tm+1)
+	 */
+	if r != 0 {
+		var cc, pp [blockSize]byte
+		c.cryptBlock(c.k1.Encrypt, cc[:], plaintext[n:], &tweak)
+		c.mulAlpha(&tweak)
+
+		copy(pp[:], plaintext[n+blockSize:])
+		copy(pp[r:], cc[r:])
+		copy(ciphertext[n+blockSize:], cc[:r])
+		c.cryptBlock(c.k1.Encrypt, ciphertext[n:], pp[:], &tweak)
+	}
+}
+
+//Decrypt - 使用给定的128比特调柄解密
+func (c *Cipher) Decrypt(plaintext, ciphertext []byte, tweak [blockSize]byte) {
+	c.check(plaintext, ciphertext)
+	c.k2.Encrypt(tweak[:], tweak[:])
+
+	n := len(ciphertext) / blockSize * blockSize
+	r := len(ciphertext) - n
+	if r != 0 {
+		n -= blockSize
+	}
+
+	c.cryptBlocks(false, plaintext[:n], ciphertext[:n], &tweak)
+
+	/**
+	 * 密文挪用:
+	 * PP = D(Cm, Tm+1)
+	 * Pm+1 = PP[:r]
+	 * Pm = D(Cm+1 || PP[r:], Tm)
+	 */
+	if r != 0 {
+		var cc, pp [blockSize]byte
+		next := tweak
+		c.mulAlpha(&next)
+		c.cryptBlock(c.k1.Decrypt, pp[:], ciphertext[n:], &next)
+
+		copy(cc[:], ciphertext[n+blockSize:])
+		copy(cc[r:], pp[r:])
+		copy(plaintext[n+blockSize:], pp[:r])
+		c.cryptBlock(c.k1.Decrypt, plaintext[n:], cc[:], &tweak)
+	}
+}
+
+/**
+ * T = T ⊗ α
+ */
+func (c *Cipher) mulAlpha(tweak *[blockSize]byte) {
+	if c.gb {
+		var carry byte
+		for i := range tweak {
+			next := tweak[i] << 7
+			tweak[i] = tweak[i]>>1 | carry
+			carry = next
+		}
+		if carry != 0 {
+			tweak[0] ^= 0xE1
+		}
+		return
+	}
+
+	var carry byte
+	for i := range tweak {
+		next := tweak[i] >> 7
+		tweak[i] = tweak[i]<<1 | carry
+		carry = next
+	}
+	if carry != 0 {
+		tweak[0] ^= 0x87
+	}
+}
@@ -0,0 +1,100 @@
+package zuc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMsgLen = errors.New("zuc: message shorter than bitLen")
+
+/**
+ * 128-EEA3 初始向量
+ * IV[0..3] = COUNT, IV[4] = BEARER || DIRECTION || 00, IV[5..7] = 0, IV[8..15] = IV[0..7]
+ */
+func eea3IV(count, bearer, direction uint32) []byte {
+	iv := make([]byte, IVSize)
+	binary.BigEndian.PutUint32(iv, count)
+	iv[4] = byte(((bearer << 1) | (direction & 1)) << 2)
+	copy(iv[8:], iv[:8])
+	return iv
+}
+
+/**
+ * 128-EIA3 初始向量
+ * IV[0..3] = COUNT, IV[4] = BEARER || 000, IV[5..7] = 0
+ * IV[8] = IV[0] ^ (DIRECTION << 7), IV[9..13] = IV[1..5]
+ * IV[14] = IV[6] ^ (DIRECTION << 7), IV[15] = IV[7]
+ */
+func eia3IV(count, bearer, direction uint32) []byte {
+	iv := make([]byte, IVSize)
+	binary.BigEndian.PutUint32(iv, count)
+	iv[4] = byte(bearer << 3)
+	copy(iv[8:], iv[:8])
+	iv[8] ^= byte(direction << 7)
+	iv[14] ^= byte(direction << 7)
+	return iv
+}
+
+//NewEEA3 - 创建 128-EEA3 机密性算法的密钥流，bearer 为5比特，direction 为1比特
+func NewEEA3(key []byte, count, bearer, direction uint32) (*ZUC, error) {
+	return NewCipher(key, eea3IV(count, bearer, direction))
+}
+
+//EEA3 - 对 bitLen 比特长度的消息进行 128-EEA3 加解密，输出中超出 bitLen 的比特置0
+func EEA3(key []byte, count, bearer, direction uint32, msg []byte, bitLen int) ([]byte, error) {
+	if bitLen < 0 || len(msg)*8 < bitLen {
+		return nil, errMsgLen
+	}
+	zuc, err := NewEEA3(key, count, bearer, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	n := (bitLen + 7) / 8
+	out := make([]byte, n)
+	zuc.XORKeyStream(out, msg[:n])
+	if bitLen%8 != 0 {
+		out[n-1] &= 0xFF << uint(8-bitLen%8)
+	}
+	return out, nil
+}
+
+/**
+ * 取密钥流中从第 i 比特开始的32比特
+ */
+func getWord(z []uint32, i int) uint32 {
+	j, k := i/32, uint(i%32)
+	if k == 0 {
+		return z[j]
+	}
+	return z[j]<<k | z[j+1]>>(32-k)
+}
+
+//EIA3 - 计算 bitLen 比特长度消息的 128-EIA3 消息认证码
+func EIA3(key []byte, count, bearer, direction uint32, msg []byte, bitLen int) (uint32, error) {
+	if bitLen < 0 || len(msg)*8 < bitLen {
+		return 0, errMsgLen
+	}
+	zuc, err := NewCipher(key, eia3IV(count, bearer, direction))
+	if err != nil {
+		return 0, err
+	}
+
+	/**
+	 * L = ⌈(LENGTH + 64) / 32⌉ 个密钥字
+	 */
+	l := (bitLen + 64 + 31) / 32
+	z := make([]uint32, l)
+	for i := range z {
+		z[i] = zuc.GenerateKeyWord()
+	}
+
+	var t uint32
+	for i := 0; i < bitLen; i++ {
+		if msg[i/8]&(0x80>>uint(i%8)) != 0 {
+			t ^= getWord(z, i)
+		}
+	}
+	t ^= getWord(z, bitLen)
+	return t ^ z[l-1], nil
+}
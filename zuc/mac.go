@@ -0,0 +1,144 @@
+package zuc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//MAC256 - ZUC-256 消息认证码，实现 hash.Hash，标签长度为32、64或128比特
+type MAC256 struct {
+	key, iv []byte
+	d       *[16]byte
+	words   int // 标签的32比特字数 t/32
+
+	zuc *ZUC
+
+	/**
+	 * tag: 当前标签
+	 * window: 从第 t+L 比特开始的 t+32 比特密钥流，L 为已处理的消息比特数
+	 */
+	tag    [4]uint32
+	window [5]uint32
+
+	buf [4]byte
+	n   int
+}
+
+//NewMAC256 - tagSize 为标签的字节长度: 4, 8 或 16
+func NewMAC256(key, iv []byte, tagSize int) (*MAC256, error) {
+	var d *[16]byte
+	switch tagSize {
+	case 4:
+		d = &d256MAC32
+	case 8:
+		d = &d256MAC64
+	case 16:
+		d = &d256MAC128
+	default:
+		return nil, errors.New("invalid size of tag, only support 4, 8 or 16 bytes.")
+	}
+
+	m := &MAC256{
+		key:   append([]byte{}, key...),
+		iv:    append([]byte{}, iv...),
+		d:     d,
+		words: tagSize / 4,
+	}
+	if err := m.reset(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MAC256) reset() error {
+	zuc, err := newZUC256(m.key, m.iv, m.d)
+	if err != nil {
+		return err
+	}
+	m.zuc = zuc
+	for i := 0; i < m.words; i++ {
+		m.tag[i] = zuc.GenerateKeyWord()
+	}
+	for i := 0; i <= m.words; i++ {
+		m.window[i] = zuc.GenerateKeyWord()
+	}
+	m.n = 0
+	return nil
+}
+
+/**
+ * 取窗口中从第 i 比特开始的 t 比特，异或进标签
+ */
+func (m *MAC256) xorWindow(i uint) {
+	for j := 0; j < m.words; j++ {
+		if i == 0 {
+			m.tag[j] ^= m.window[j]
+		} else {
+			m.tag[j] ^= m.window[j]<<i | m.window[j+1]>>(32-i)
+		}
+	}
+}
+
+func (m *MAC256) processBits(w uint32, bits int) {
+	for i := 0; i < bits; i++ {
+		if w&(0x80000000>>uint(i)) != 0 {
+			m.xorWindow(uint(i))
+		}
+	}
+}
+
+func (m *MAC256) processWord(w uint32) {
+	m.processBits(w, 32)
+	copy(m.window[:m.words], m.window[1:m.words+1])
+	m.window[m.words] = m.zuc.GenerateKeyWord()
+}
+
+//Write - implements hash.Hash
+func (m *MAC256) Write(p []byte) (int, error) {
+	nn := len(p)
+	if m.n > 0 {
+		c := copy(m.buf[m.n:], p)
+		m.n += c
+		p = p[c:]
+		if m.n < len(m.buf) {
+			return nn, nil
+		}
+		m.processWord(binary.BigEndian.Uint32(m.buf[:]))
+		m.n = 0
+	}
+	for ; len(p) >= 4; p = p[4:] {
+		m.processWord(binary.BigEndian.Uint32(p))
+	}
+	m.n = copy(m.buf[:], p)
+	return nn, nil
+}
+
+//Sum - implements hash.Hash, 不改变当前状态
+func (m *MAC256) Sum(b []byte) []byte {
+	d := *m
+	var w [4]byte
+	copy(w[:], d.buf[:d.n])
+	bits := d.n * 8
+	d.processBits(binary.BigEndian.Uint32(w[:]), bits)
+	d.xorWindow(uint(bits))
+
+	for i := 0; i < d.words; i++ {
+		b = append(b, byte(d.tag[i]>>24), byte(d.tag[i]>>16), byte(d.tag[i]>>8), byte(d.tag[i]))
+	}
+	return b
+}
+
+//Reset - implements hash.Hash
+func (m *MAC256) Reset() {
+	m.reset()
+}
+
+//Size - 标签的字节长度
+func (m *MAC256) Size() int {
+	return m.words * 4
+}
+
+//BlockSize - implements hash.Hash
+func (m *MAC256) BlockSize() int {
+	return 4
+}
@@ -0,0 +1,174 @@
+package zuc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+const (
+	KeySize = 16
+	IVSize  = 16
+)
+
+//ZUC - 祖冲之序列密码 (GB/T 33133.1)，实现 cipher.Stream
+type ZUC struct {
+	/**
+	 * 线性反馈移位寄存器，16个31比特单元 s0 ~ s15
+	 */
+	lfsr [16]uint32
+
+	/**
+	 * 非线性函数F的两个32比特记忆单元
+	 */
+	r1, r2 uint32
+
+	/**
+	 * 比特重组输出 X0 ~ X3
+	 */
+	x [4]uint32
+
+	/**
+	 * 未使用完的密钥流字节
+	 */
+	keyStream [4]byte
+	off       int
+}
+
+//NewCipher - 使用128比特密钥和128比特初始向量创建 ZUC-128
+func NewCipher(key, iv []byte) (*ZUC, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("invalid size of key, only support 16 bytes.")
+	}
+	if len(iv) != IVSize {
+		return nil, errors.New("invalid size of iv, only support 16 bytes.")
+	}
+
+	zuc := &ZUC{}
+	for i := 0; i < 16; i++ {
+		zuc.lfsr[i] = uint32(key[i])<<23 | D[i]<<8 | uint32(iv[i])
+	}
+	zuc.init()
+	return zuc, nil
+}
+
+/**
+ * 初始化阶段: 32轮初始化模式，之后执行一次工作模式并丢弃F的输出
+ */
+func (zuc *ZUC) init() {
+	zuc.r1, zuc.r2 = 0, 0
+	for i := 0; i < 32; i++ {
+		zuc.bitReorganization()
+		w := zuc.f()
+		zuc.lfsrWithInitMode(w >> 1)
+	}
+	zuc.bitReorganization()
+	zuc.f()
+	zuc.lfsrWithWorkMode()
+	zuc.off = len(zuc.keyStream)
+}
+
+/**
+ * a + b mod (2^31 - 1)
+ */
+func addM(a, b uint32) uint32 {
+	c := a + b
+	return (c & 0x7FFFFFFF) + (c >> 31)
+}
+
+func rotl31(a uint32, k uint) uint32 {
+	return ((a << k) | (a >> (31 - k))) & 0x7FFFFFFF
+}
+
+/**
+ * v = 2^15*s15 + 2^17*s13 + 2^21*s10 + 2^20*s4 + (1 + 2^8)*s0 mod (2^31 - 1)
+ */
+func (zuc *ZUC) lfsrNext() uint32 {
+	s := &zuc.lfsr
+	v := s[0]
+	v = addM(v, rotl31(s[0], 8))
+	v = addM(v, rotl31(s[4], 20))
+	v = addM(v, rotl31(s[10], 21))
+	v = addM(v, rotl31(s[13], 17))
+	v = addM(v, rotl31(s[15], 15))
+	return v
+}
+
+func (zuc *ZUC) lfsrShift(s16 uint32) {
+	if s16 == 0 {
+		s16 = 0x7FFFFFFF
+	}
+	copy(zuc.lfsr[:15], zuc.lfsr[1:])
+	zuc.lfsr[15] = s16
+}
+
+func (zuc *ZUC) lfsrWithInitMode(u uint32) {
+	zuc.lfsrShift(addM(zuc.lfsrNext(), u))
+}
+
+func (zuc *ZUC) lfsrWithWorkMode() {
+	zuc.lfsrShift(zuc.lfsrNext())
+}
+
+/**
+ * 比特重组
+ * X0 = s15H || s14L, X1 = s11L || s9H, X2 = s7L || s5H, X3 = s2L || s0H
+ */
+func (zuc *ZUC) bitReorganization() {
+	s := &zuc.lfsr
+	zuc.x[0] = ((s[15] & 0x7FFF8000) << 1) | (s[14] & 0xFFFF)
+	zuc.x[1] = ((s[11] & 0xFFFF) << 16) | (s[9] >> 15)
+	zuc.x[2] = ((s[7] & 0xFFFF) << 16) | (s[5] >> 15)
+	zuc.x[3] = ((s[2] & 0xFFFF) << 16) | (s[0] >> 15)
+}
+
+func l1(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 2) ^ bits.RotateLeft32(x, 10) ^ bits.RotateLeft32(x, 18) ^ bits.RotateLeft32(x, 24)
+}
+
+func l2(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 8) ^ bits.RotateLeft32(x, 14) ^ bits.RotateLeft32(x, 22) ^ bits.RotateLeft32(x, 30)
+}
+
+func sbox(x uint32) uint32 {
+	return uint32(S0[x>>24])<<24 | uint32(S1[(x>>16)&0xFF])<<16 | uint32(S0[(x>>8)&0xFF])<<8 | uint32(S1[x&0xFF])
+}
+
+/**
+ * 非线性函数F
+ * W = (X0 ^ R1) + R2, W1 = R1 + X1, W2 = R2 ^ X2
+ * R1 = S(L1(W1L || W2H)), R2 = S(L2(W2L || W1H))
+ */
+func (zuc *ZUC) f() uint32 {
+	w := (zuc.x[0] ^ zuc.r1) + zuc.r2
+	w1 := zuc.r1 + zuc.x[1]
+	w2 := zuc.r2 ^ zuc.x[2]
+	u := l1((w1 << 16) | (w2 >> 16))
+	v := l2((w2 << 16) | (w1 >> 16))
+	zuc.r1 = sbox(u)
+	zuc.r2 = sbox(v)
+	return w
+}
+
+//GenerateKeyWord - 输出一个32比特密钥字
+func (zuc *ZUC) GenerateKeyWord() uint32 {
+	zuc.bitReorganization()
+	z := zuc.f() ^ zuc.x[3]
+	zuc.lfsrWithWorkMode()
+	return z
+}
+
+//XORKeyStream - implements cipher.Stream
+func (zuc *ZUC) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("zuc: output smaller than input")
+	}
+	for i := range src {
+		if zuc.off == len(zuc.keyStream) {
+			binary.BigEndian.PutUint32(zuc.keyStream[:], zuc.GenerateKeyWord())
+			zuc.off = 0
+		}
+		dst[i] = src[i] ^ zuc.keyStream[zuc.off]
+		zuc.off++
+	}
+}
@@ -0,0 +1,65 @@
+package zuc
+
+import (
+	"errors"
+)
+
+const (
+	KeySize256 = 32
+
+	/**
+	 * IV0 ~ IV16 为8比特，IV17 ~ IV24 为6比特，每个占用一个字节
+	 */
+	IVSize256 = 25
+)
+
+//NewCipher256 - 使用256比特密钥和184比特初始向量创建 ZUC-256
+func NewCipher256(key, iv []byte) (*ZUC, error) {
+	return newZUC256(key, iv, &d256)
+}
+
+func newZUC256(key, iv []byte, d *[16]byte) (*ZUC, error) {
+	if len(key) != KeySize256 {
+		return nil, errors.New("invalid size of key, only support 32 bytes.")
+	}
+	if len(iv) != IVSize256 {
+		return nil, errors.New("invalid size of iv, only support 25 bytes.")
+	}
+
+	zuc := &ZUC{}
+	zuc.loadKey256(key, iv, d)
+	zuc.init()
+	return zuc, nil
+}
+
+func makeU31(a, b, c, d byte) uint32 {
+	return uint32(a)<<23 | uint32(b)<<16 | uint32(c)<<8 | uint32(d)
+}
+
+/**
+ * ZUC-256 密钥装入，每个单元为 8 || 7 || 8 || 8 比特
+ */
+func (zuc *ZUC) loadKey256(k, iv []byte, d *[16]byte) {
+	var iv6 [8]byte
+	for i := range iv6 {
+		iv6[i] = iv[17+i] & 0x3F
+	}
+
+	s := &zuc.lfsr
+	s[0] = makeU31(k[0], d[0], k[21], k[16])
+	s[1] = makeU31(k[1], d[1], k[22], k[17])
+	s[2] = makeU31(k[2], d[2], k[23], k[18])
+	s[3] = makeU31(k[3], d[3], k[24], k[19])
+	s[4] = makeU31(k[4], d[4], k[25], k[20])
+	s[5] = makeU31(iv[0], d[5]|iv6[0], k[5], k[26])
+	s[6] = makeU31(iv[1], d[6]|iv6[1], k[6], k[27])
+	s[7] = makeU31(iv[10], d[7]|iv6[2], k[7], iv[2])
+	s[8] = makeU31(k[8], d[8]|iv6[3], iv[3], iv[11])
+	s[9] = makeU31(k[9], d[9]|iv6[4], iv[12], iv[4])
+	s[10] = makeU31(iv[5], d[10]|iv6[5], k[10], k[28])
+	s[11] = makeU31(k[11], d[11]|iv6[6], iv[6], iv[13])
+	s[12] = makeU31(k[12], d[12]|iv6[7], iv[7], iv[14])
+	s[13] = makeU31(k[13], d[13], iv[15], iv[8])
+	s[14] = makeU31(k[14], d[14]|(k[31]>>4), iv[16], iv[9])
+	s[15] = makeU31(k[15], d[15]|(k[31]&0x0F), k[30], k[29])
+}
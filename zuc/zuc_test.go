@@ -0,0 +1,233 @@
+package zuc
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/**
+ * GB/T 33133.1 附录A / 3GPP ZUC 测试向量
+ */
+var keyStreamTests = []struct {
+	key, iv string
+	z1, z2  uint32
+}{
+	{"00000000000000000000000000000000", "00000000000000000000000000000000", 0x27bede74, 0x018082da},
+	{"ffffffffffffffffffffffffffffffff", "ffffffffffffffffffffffffffffffff", 0x0657cfa0, 0x7096398b},
+	{"3d4c4be96a82fdaeb58f641db17b455b", "84319aa8de6915ca1f6bda6bfbd8c766", 0x14f1c272, 0x3279c419},
+}
+
+func TestZUC_GenerateKeyWord(t *testing.T) {
+	for i, test := range keyStreamTests {
+		zuc, err := NewCipher(mustHex(test.key), mustHex(test.iv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if z1, z2 := zuc.GenerateKeyWord(), zuc.GenerateKeyWord(); z1 != test.z1 || z2 != test.z2 {
+			t.Fatalf("#%d: invalid key stream %08x %08x", i, z1, z2)
+		}
+	}
+}
+
+func TestZUC_XORKeyStream(t *testing.T) {
+	test := keyStreamTests[2]
+	var stream cipher.Stream
+	stream, _ = NewCipher(mustHex(test.key), mustHex(test.iv))
+
+	// 分段调用与一次性调用结果一致
+	out := make([]byte, 8)
+	stream.XORKeyStream(out[:3], out[:3])
+	stream.XORKeyStream(out[3:], out[3:])
+	if !bytes.Equal(out, mustHex("14f1c2723279c419")) {
+		t.Fatal("invalid key stream")
+	}
+}
+
+/**
+ * 3GPP 128-EEA3 测试向量
+ */
+var eea3Tests = []struct {
+	key                      string
+	count, bearer, direction uint32
+	bitLen                   int
+	plain, cipher            string
+}{
+	{
+		"173d14ba5003731d7a60049470f00a29", 0x66035492, 0x0f, 0, 193,
+		"6cf65340735552ab0c9752fa6f9025fe0bd675d9005875b200000000",
+		"a6c85fc66afb8533aafc2518dfe784940ee1e4b030238cc800000000",
+	},
+	{
+		"e5bd3ea0eb55ade866c6ac58bd54302a", 0x00056823, 0x18, 1, 800,
+		"14a8ef693d678507bbe7270a7f67ff5006c3525b9807e467c4e56000ba338f5d42955903675182224" +
+			"6c80d3b38f07f4be2d8ff5805f5132229bde93bbbdcaf382bf1ee972fbf9977bada8945847a2a6c9ad34a" +
+			"667554e04d1f7fa2c33241bd8f01ba220d",
+		"131d43e0dea1be5c5a1bfd971d852cbf712d7b4f57961fea3208afa8bca433f456ad09c7417e58bc6" +
+			"9cf8866d1353f74865e80781d202dfb3ecff7fcbc3b190fe82a204ed0e350fc0f6f2613b2f2bca6df5a47" +
+			"3a57a4a00d985ebad880d6f23864a07b01",
+	},
+}
+
+func TestEEA3(t *testing.T) {
+	for i, test := range eea3Tests {
+		out, err := EEA3(mustHex(test.key), test.count, test.bearer, test.direction, mustHex(test.plain), test.bitLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := mustHex(test.cipher)[:(test.bitLen+7)/8]
+		if !bytes.Equal(out, expected) {
+			t.Fatalf("#%d: invalid encrypt %x", i, out)
+		}
+
+		plain, _ := EEA3(mustHex(test.key), test.count, test.bearer, test.direction, out, test.bitLen)
+		if !bytes.Equal(plain, mustHex(test.plain)[:(test.bitLen+7)/8]) {
+			t.Fatalf("#%d: invalid decrypt", i)
+		}
+	}
+}
+
+/**
+ * 3GPP 128-EIA3 测试向量
+ */
+var eia3Tests = []struct {
+	key                      string
+	count, bearer, direction uint32
+	bitLen                   int
+	msg                      string
+	mac                      uint32
+}{
+	{"00000000000000000000000000000000", 0, 0, 0, 1, "00000000", 0xc8a9595e},
+	{"47054125561eb2dda94059da05097850", 0x561eb2dd, 0x14, 0, 90, "000000000000000000000000", 0x6719a088},
+}
+
+func TestEIA3(t *testing.T) {
+	for i, test := range eia3Tests {
+		mac, err := EIA3(mustHex(test.key), test.count, test.bearer, test.direction, mustHex(test.msg), test.bitLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mac != test.mac {
+			t.Fatalf("#%d: invalid mac %08x", i, mac)
+		}
+	}
+
+	if _, err := EIA3(make([]byte, KeySize), 0, 0, 0, []byte{0}, 9); err != errMsgLen {
+		t.Fatal("short message should fail")
+	}
+}
+
+func iv256(b byte) []byte {
+	iv := bytes.Repeat([]byte{b}, IVSize256)
+	for i := 17; i < IVSize256; i++ {
+		iv[i] &= 0x3F
+	}
+	return iv
+}
+
+/**
+ * ZUC-256 测试向量，前20个密钥字
+ */
+var keyStream256Tests = []struct {
+	key, iv []byte
+	z       string
+}{
+	{
+		make([]byte, KeySize256), iv256(0x00),
+		"58d03ad62e032ce2dafc683a39bdcb0352a2bc67f1b7de74163ce3a101ef55589639d75b95fa681b7f090df756391ccc" +
+			"903b7612744d544c17bc3fad8b163b0821787c0b97775bb84943c6bbe8ad8afd",
+	},
+	{
+		bytes.Repeat([]byte{0xFF}, KeySize256), iv256(0xFF),
+		"3356cbaed1a1c18b6baa4ffe343f777c9e15128f251ab65b949f7b26ef7157f296dd2fa9df95e3ee7a5be02ec32ba585" +
+			"505af316c2f9ded27cdbd935e441ce1115fd0a80bb7aef6768989416b8fac8c2",
+	},
+}
+
+func TestZUC256(t *testing.T) {
+	for i, test := range keyStream256Tests {
+		zuc, err := NewCipher256(test.key, test.iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(test.z)/2)
+		zuc.XORKeyStream(out, out)
+		if hex.EncodeToString(out) != test.z {
+			t.Fatalf("#%d: invalid key stream %x", i, out)
+		}
+	}
+}
+
+/**
+ * ZUC-256 MAC 测试向量
+ */
+var mac256Tests = []struct {
+	key, iv []byte
+	msg     []byte
+	tag32   string
+	tag64   string
+	tag128  string
+}{
+	{
+		make([]byte, KeySize256), iv256(0x00), make([]byte, 50),
+		"9b972a74", "673e54990034d38c", "d85e54bbcb9600967084c952a1654b26",
+	},
+	{
+		make([]byte, KeySize256), iv256(0x00), bytes.Repeat([]byte{0x11}, 500),
+		"8754f5cf", "130dc225e72240cc", "df1e8307b31cc62beca1ac6f8190c22f",
+	},
+	{
+		bytes.Repeat([]byte{0xFF}, KeySize256), iv256(0xFF), make([]byte, 50),
+		"1f3079b4", "8c71394d39957725", "a35bb274b567c48b28319f111af34fbd",
+	},
+}
+
+func TestMAC256(t *testing.T) {
+	for i, test := range mac256Tests {
+		for _, expected := range []string{test.tag32, test.tag64, test.tag128} {
+			mac, err := NewMAC256(test.key, test.iv, len(expected)/2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mac.Write(test.msg)
+			if tag := hex.EncodeToString(mac.Sum(nil)); tag != expected {
+				t.Fatalf("#%d: invalid tag %s", i, tag)
+			}
+		}
+	}
+}
+
+func TestMAC256_Write(t *testing.T) {
+	test := mac256Tests[1]
+	mac, _ := NewMAC256(test.key, test.iv, 16)
+	for i := 0; i < len(test.msg); i += 7 {
+		end := i + 7
+		if end > len(test.msg) {
+			end = len(test.msg)
+		}
+		mac.Write(test.msg[i:end])
+		mac.Sum(nil)
+	}
+	if tag := hex.EncodeToString(mac.Sum(nil)); tag != test.tag128 {
+		t.Fatal("invalid tag")
+	}
+
+	mac.Reset()
+	mac.Write(test.msg)
+	if tag := hex.EncodeToString(mac.Sum(nil)); tag != test.tag128 {
+		t.Fatal("invalid tag after reset")
+	}
+
+	if _, err := NewMAC256(test.key, test.iv, 12); err == nil {
+		t.Fatal("invalid tag size should fail")
+	}
+}